@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/configs"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/limiter"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/storage"
+)
+
+const usage = `Usage: ratelimitctl <command> [arguments]
+
+Commands:
+  status  -ip <ip> | -token <token> | -key <key>   Show the counter and block of a key
+  blocks                                            List active blocks
+  unblock <key>                                     Remove the block of a key
+  reset   <key>                                     Clear the counter and block of a key
+  export                                            Print counters and blocks as JSON
+  hash-token [token]                                Print the SHA-256 hash a token is stored as;
+                                                    the token is read from stdin when omitted
+`
+
+type keyStatus struct {
+	Key          string     `json:"key"`
+	Count        int64      `json:"count"`
+	Blocked      bool       `json:"blocked"`
+	BlockedUntil *time.Time `json:"blocked_until,omitempty"`
+}
+
+type counterState struct {
+	Key       string    `json:"key"`
+	Count     int64     `json:"count"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type blockState struct {
+	Key       string    `json:"key"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type exportState struct {
+	ExportedAt time.Time      `json:"exported_at"`
+	Counters   []counterState `json:"counters"`
+	Blocks     []blockState   `json:"blocks"`
+}
+
+func main() {
+	cfg, err := configs.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Unlike the server, there is no in-memory fallback: an empty local
+	// store would only show misleading state.
+	store, err := storage.NewRedisStorage(cfg.RedisHost, cfg.RedisPort, cfg.RedisPassword, cfg.RedisDB)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+
+	err = run(context.Background(), os.Args[1:], cfg, limiter.NewRateLimiter(store), os.Stdin, os.Stdout)
+	store.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, cfg *configs.Config, rateLimiter *limiter.RateLimiter, in io.Reader, out io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(out, usage)
+		return errors.New("missing command")
+	}
+
+	command, args := args[0], args[1:]
+
+	switch command {
+	case "status":
+		return status(ctx, args, cfg, rateLimiter, out)
+	case "blocks":
+		return blocks(ctx, rateLimiter, out)
+	case "unblock":
+		key, err := keyArg(args)
+		if err != nil {
+			return err
+		}
+		if err := rateLimiter.Unblock(ctx, key); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%s unblocked\n", key)
+		return nil
+	case "reset":
+		key, err := keyArg(args)
+		if err != nil {
+			return err
+		}
+		if err := rateLimiter.Reset(ctx, key); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%s reset\n", key)
+		return nil
+	case "export":
+		return export(ctx, rateLimiter, out)
+	case "hash-token":
+		token, err := tokenArg(args, in)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, configs.HashToken(token))
+		return nil
+	case "help", "-h", "--help":
+		fmt.Fprint(out, usage)
+		return nil
+	default:
+		fmt.Fprint(out, usage)
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
+
+func status(ctx context.Context, args []string, cfg *configs.Config, rateLimiter *limiter.RateLimiter, out io.Writer) error {
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	fs.SetOutput(out)
+	ip := fs.String("ip", "", "client IP address")
+	token := fs.String("token", "", "API token")
+	rawKey := fs.String("key", "", "raw limiter key")
+	asJSON := fs.Bool("json", false, "print the status as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var key string
+	switch {
+	case *ip != "":
+		addr, err := netip.ParseAddr(*ip)
+		if err != nil {
+			return fmt.Errorf("invalid IP address %q", *ip)
+		}
+		// Same subnet aggregation as the middleware
+		key = fmt.Sprintf("ip:%s", cfg.AggregateIP(addr))
+	case *token != "":
+		key = fmt.Sprintf("token:%s", configs.HashToken(*token))
+	case *rawKey != "":
+		key = *rawKey
+	default:
+		return errors.New("one of -ip, -token or -key is required")
+	}
+
+	count, err := rateLimiter.GetCurrentCount(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	result := keyStatus{Key: key, Count: count}
+
+	blockTTL, err := rateLimiter.BlockTTL(ctx, key)
+	if err != nil {
+		return err
+	}
+	if blockTTL > 0 {
+		expiresAt := time.Now().Add(blockTTL)
+		result.Blocked = true
+		result.BlockedUntil = &expiresAt
+	}
+
+	if *asJSON {
+		return writeJSON(out, result)
+	}
+
+	fmt.Fprintf(out, "key:     %s\n", result.Key)
+	fmt.Fprintf(out, "count:   %d\n", result.Count)
+	if result.Blocked {
+		fmt.Fprintf(out, "blocked: yes (until %s)\n", result.BlockedUntil.Format(time.RFC3339))
+	} else {
+		fmt.Fprintln(out, "blocked: no")
+	}
+	return nil
+}
+
+func blocks(ctx context.Context, rateLimiter *limiter.RateLimiter, out io.Writer) error {
+	activeBlocks, err := rateLimiter.ListBlocks(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(activeBlocks) == 0 {
+		fmt.Fprintln(out, "no active blocks")
+		return nil
+	}
+
+	sort.Slice(activeBlocks, func(i, j int) bool { return activeBlocks[i].Key < activeBlocks[j].Key })
+	for _, block := range activeBlocks {
+		remaining := time.Until(block.ExpiresAt).Round(time.Second)
+		fmt.Fprintf(out, "%s\t%s remaining\n", block.Key, remaining)
+	}
+	return nil
+}
+
+func export(ctx context.Context, rateLimiter *limiter.RateLimiter, out io.Writer) error {
+	counters, err := rateLimiter.ListCounters(ctx)
+	if err != nil {
+		return err
+	}
+
+	activeBlocks, err := rateLimiter.ListBlocks(ctx)
+	if err != nil {
+		return err
+	}
+
+	state := exportState{
+		ExportedAt: time.Now().UTC(),
+		Counters:   make([]counterState, 0, len(counters)),
+		Blocks:     make([]blockState, 0, len(activeBlocks)),
+	}
+	for _, c := range counters {
+		state.Counters = append(state.Counters, counterState{Key: c.Key, Count: c.Count, ExpiresAt: c.ExpiresAt})
+	}
+	for _, b := range activeBlocks {
+		state.Blocks = append(state.Blocks, blockState{Key: b.Key, ExpiresAt: b.ExpiresAt})
+	}
+
+	sort.Slice(state.Counters, func(i, j int) bool { return state.Counters[i].Key < state.Counters[j].Key })
+	sort.Slice(state.Blocks, func(i, j int) bool { return state.Blocks[i].Key < state.Blocks[j].Key })
+
+	return writeJSON(out, state)
+}
+
+func keyArg(args []string) (string, error) {
+	if len(args) != 1 || args[0] == "" {
+		return "", errors.New("exactly one key is required")
+	}
+	return args[0], nil
+}
+
+// tokenArg returns the token given as the only argument or, so that it stays
+// out of the shell history and the process list, read from in
+func tokenArg(args []string, in io.Reader) (string, error) {
+	if len(args) > 0 {
+		return keyArg(args)
+	}
+
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token: %w", err)
+	}
+	token := strings.TrimRight(string(data), "\r\n")
+	if token == "" {
+		return "", errors.New("a token is required, as argument or on stdin")
+	}
+	return token, nil
+}
+
+func writeJSON(out io.Writer, v any) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
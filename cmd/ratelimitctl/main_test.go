@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/configs"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/limiter"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun_StatusAndUnblock(t *testing.T) {
+	rateLimiter := limiter.NewRateLimiter(storage.NewMemoryStorage())
+	cfg := &configs.Config{IPv4Prefix: 32, IPv6Prefix: 64}
+	ctx := context.Background()
+
+	for i := 0; i <= 2; i++ {
+		rateLimiter.AllowRequest(ctx, "ip:10.0.0.1", 2, 1*time.Minute)
+	}
+
+	var out bytes.Buffer
+	err := run(ctx, []string{"status", "-ip", "10.0.0.1", "-json"}, cfg, rateLimiter, nil, &out)
+	assert.NoError(t, err)
+
+	var result keyStatus
+	json.Unmarshal(out.Bytes(), &result)
+	assert.Equal(t, "ip:10.0.0.1", result.Key)
+	assert.Equal(t, int64(3), result.Count)
+	assert.True(t, result.Blocked)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), *result.BlockedUntil, 5*time.Second)
+
+	out.Reset()
+	err = run(ctx, []string{"unblock", "ip:10.0.0.1"}, cfg, rateLimiter, nil, &out)
+	assert.NoError(t, err)
+
+	blocked, err := rateLimiter.IsBlocked(ctx, "ip:10.0.0.1")
+	assert.NoError(t, err)
+	assert.False(t, blocked)
+}
+
+func TestRun_ResetAndExport(t *testing.T) {
+	rateLimiter := limiter.NewRateLimiter(storage.NewMemoryStorage())
+	cfg := &configs.Config{IPv4Prefix: 32, IPv6Prefix: 64}
+	ctx := context.Background()
+
+	rateLimiter.AllowRequest(ctx, "token:abc123", 10, 1*time.Minute)
+	rateLimiter.AllowRequest(ctx, "ip:10.0.0.2", 10, 1*time.Minute)
+
+	var out bytes.Buffer
+	err := run(ctx, []string{"reset", "token:abc123"}, cfg, rateLimiter, nil, &out)
+	assert.NoError(t, err)
+
+	out.Reset()
+	err = run(ctx, []string{"export"}, cfg, rateLimiter, nil, &out)
+	assert.NoError(t, err)
+
+	var state exportState
+	json.Unmarshal(out.Bytes(), &state)
+	assert.Len(t, state.Counters, 1)
+	assert.Equal(t, "ip:10.0.0.2", state.Counters[0].Key)
+	assert.Empty(t, state.Blocks)
+}
+
+func TestRun_InvalidCommand(t *testing.T) {
+	rateLimiter := limiter.NewRateLimiter(storage.NewMemoryStorage())
+	cfg := &configs.Config{IPv4Prefix: 32, IPv6Prefix: 64}
+	ctx := context.Background()
+	var out bytes.Buffer
+
+	assert.Error(t, run(ctx, nil, cfg, rateLimiter, nil, &out))
+	assert.Error(t, run(ctx, []string{"unknown"}, cfg, rateLimiter, nil, &out))
+	assert.Error(t, run(ctx, []string{"unblock"}, cfg, rateLimiter, nil, &out))
+	assert.Error(t, run(ctx, []string{"status"}, cfg, rateLimiter, nil, &out))
+	assert.Error(t, run(ctx, []string{"status", "-ip", "not-an-ip"}, cfg, rateLimiter, nil, &out))
+}
+
+func TestRun_StatusAggregatesIPv6(t *testing.T) {
+	rateLimiter := limiter.NewRateLimiter(storage.NewMemoryStorage())
+	cfg := &configs.Config{IPv4Prefix: 32, IPv6Prefix: 64}
+	ctx := context.Background()
+
+	rateLimiter.AllowRequest(ctx, "ip:2001:db8:1:2::/64", 10, 1*time.Minute)
+
+	var out bytes.Buffer
+	err := run(ctx, []string{"status", "-ip", "2001:db8:1:2::abcd", "-json"}, cfg, rateLimiter, nil, &out)
+	assert.NoError(t, err)
+
+	var result keyStatus
+	json.Unmarshal(out.Bytes(), &result)
+	assert.Equal(t, "ip:2001:db8:1:2::/64", result.Key)
+	assert.Equal(t, int64(1), result.Count)
+}
+
+func TestRun_HashToken(t *testing.T) {
+	rateLimiter := limiter.NewRateLimiter(storage.NewMemoryStorage())
+	cfg := &configs.Config{IPv4Prefix: 32, IPv6Prefix: 64}
+	ctx := context.Background()
+
+	var out bytes.Buffer
+	err := run(ctx, []string{"hash-token", "abc123"}, cfg, rateLimiter, nil, &out)
+	assert.NoError(t, err)
+	assert.Equal(t, configs.HashToken("abc123")+"\n", out.String())
+
+	// Without an argument the token is read from stdin
+	out.Reset()
+	err = run(ctx, []string{"hash-token"}, cfg, rateLimiter, strings.NewReader("abc123\n"), &out)
+	assert.NoError(t, err)
+	assert.Equal(t, configs.HashToken("abc123")+"\n", out.String())
+
+	assert.Error(t, run(ctx, []string{"hash-token"}, cfg, rateLimiter, strings.NewReader(""), &out))
+
+	// Token counters are looked up by hash
+	rateLimiter.AllowRequest(ctx, "token:"+configs.HashToken("abc123"), 10, 1*time.Minute)
+
+	out.Reset()
+	err = run(ctx, []string{"status", "-token", "abc123", "-json"}, cfg, rateLimiter, nil, &out)
+	assert.NoError(t, err)
+
+	var result keyStatus
+	json.Unmarshal(out.Bytes(), &result)
+	assert.Equal(t, "token:"+configs.HashToken("abc123"), result.Key)
+	assert.Equal(t, int64(1), result.Count)
+}
@@ -1,92 +1,225 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/configs"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/admin"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/gateway"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/health"
 	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/limiter"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/logging"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/metrics"
 	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/middleware"
 	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/storage"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/tracing"
 )
 
 func main() {
+	if err := run(); err != nil {
+		slog.Error("Server stopped", "error", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
 	cfg, err := configs.LoadConfig()
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("refusing to start: %w", err)
+	}
+
+	logger := logging.New(os.Stderr, cfg.LogFormat, cfg.LogLevel)
+	slog.SetDefault(logger)
+
+	// The deferred calls run in reverse order: storage is closed after the
+	// servers have drained, and the last spans are flushed after that
+	tracerProvider, shutdownTracing, err := tracing.Setup(cfg, os.Stdout)
+	if err != nil {
+		return fmt.Errorf("refusing to start: %w", err)
 	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			slog.Error("Error flushing traces", "error", err)
+		}
+	}()
 
 	var store storage.Storage
+	backend, fallback := "redis", false
+	m := metrics.New()
 
 	redisStore, err := storage.NewRedisStorage(cfg.RedisHost, cfg.RedisPort, cfg.RedisPassword, cfg.RedisDB)
 	if err != nil {
-		log.Printf("Warning: Failed to connect to Redis: %v", err)
-		log.Println("Falling back to in-memory storage")
+		slog.Warn("Failed to connect to Redis, falling back to in-memory storage", "error", err)
 		store = storage.NewMemoryStorage()
+		backend, fallback = "memory", true
+		m.SetFallback(true)
 	} else {
-		log.Println("Connected to Redis successfully")
+		slog.Info("Connected to Redis", "host", cfg.RedisHost, "port", cfg.RedisPort, "db", cfg.RedisDB)
 		store = redisStore
 	}
 
 	defer func() {
 		if err := store.Close(); err != nil {
-			log.Printf("Error closing storage: %v", err)
+			slog.Error("Error closing storage", "error", err)
 		}
 	}()
 
-	rateLimiter := limiter.NewRateLimiter(store)
+	instrumented := metrics.InstrumentStorage(tracing.InstrumentStorage(store, tracerProvider, backend), m)
+	rateLimiter := limiter.NewRateLimiter(instrumented)
+	m.RegisterActiveBlocks(func(ctx context.Context) (int, error) {
+		blocks, err := rateLimiter.ListBlocks(ctx)
+		return len(blocks), err
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Limits and tokens can be reloaded without a restart, either on SIGHUP
+	// or when the configuration file changes
+	reloader := configs.NewReloader(cfg)
+	go watchSIGHUP(reloader)
+	go reloader.Watch(ctx, time.Duration(cfg.ConfigReloadInterval)*time.Second)
+
+	checker := health.NewChecker(store, 2*time.Second,
+		health.WithBackend(backend, fallback),
+		health.WithConfigVersion(reloader.Version),
+	)
 
 	mux := http.NewServeMux()
 
-	// Health check endpoint (no rate limiting)
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(map[string]string{
-			"status": "healthy",
-		})
-	})
+	// Health report (exempt from rate limiting by a built-in rule)
+	mux.HandleFunc("/health", checker.Health)
+
+	// Liveness and readiness probes (also exempt by built-in rules)
+	mux.HandleFunc("/livez", checker.Livez)
+	mux.HandleFunc("/readyz", checker.Readyz)
 
-	// Main endpoint with rate limiting
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(map[string]string{
-			"message": "Welcome to Rate Limiter API",
-			"status":  "ok",
+	// Main endpoint with rate limiting: in gateway mode allowed requests are
+	// proxied to the configured upstreams
+	if cfg.Gateway != nil {
+		slog.Info("Gateway mode enabled", "routes", len(cfg.Gateway.Routes))
+		mux.Handle("/", gateway.NewHandler(reloader, logger))
+	} else {
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(map[string]string{
+				"message": "Welcome to Rate Limiter API",
+				"status":  "ok",
+			})
 		})
-	})
+	}
 
-	handler := applyMiddleware(mux, cfg, rateLimiter)
+	handler := applyMiddleware(mux, reloader, rateLimiter,
+		middleware.WithMetrics(m),
+		middleware.WithLogger(logger),
+		middleware.WithTracerProvider(tracerProvider),
+	)
+
+	slog.Info("Starting server", "port", cfg.ServerPort)
+	slog.Info("Rate limit IP", "limit", cfg.RateLimitIP, "block_time", cfg.RateLimitIPBlockTime)
+	slog.Info("Rate limit token (default)", "limit", cfg.RateLimitToken, "block_time", cfg.RateLimitTokenBlockTime)
+
+	// Tokens are only known by their hash; never log their values
+	for hash, tc := range cfg.TokenConfigs {
+		name := tc.Name
+		if name == "" {
+			name = "unnamed"
+		}
+		slog.Info("Token configuration", "name", name, "sha256", configs.ShortHash(hash), "limit", tc.Limit, "block_time", tc.BlockTime)
+	}
 
-	addr := fmt.Sprintf(":%s", cfg.ServerPort)
+	// Prometheus metrics are served on their own port, so that scrapes
+	// (which count the active blocks in the storage) are not public
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/metrics", m.Handler())
+	slog.Info("Starting metrics server", "port", cfg.MetricsPort)
 
-	log.Printf("Starting server on port %s", cfg.ServerPort)
-	log.Printf("Rate Limit IP: %d req/s (block time: %ds)", cfg.RateLimitIP, cfg.RateLimitIPBlockTime)
-	log.Printf("Rate Limit Token (default): %d req/s (block time: %ds)", cfg.RateLimitToken, cfg.RateLimitTokenBlockTime)
+	servers := []*http.Server{
+		{Addr: fmt.Sprintf(":%s", cfg.ServerPort), Handler: handler},
+		{Addr: fmt.Sprintf(":%s", cfg.MetricsPort), Handler: metricsMux},
+	}
+	if cfg.AdminToken != "" {
+		slog.Info("Starting admin API", "port", cfg.AdminPort)
+		servers = append(servers, &http.Server{Addr: fmt.Sprintf(":%s", cfg.AdminPort), Handler: admin.NewHandler(rateLimiter, cfg.AdminToken)})
+	}
 
-	if len(cfg.TokenConfigs) > 0 {
-		log.Println("Token-specific configurations:")
-		for token, tc := range cfg.TokenConfigs {
-			log.Printf("  - %s: %d req/s (block time: %ds)", token, tc.Limit, tc.BlockTime)
-		}
+	errs := make(chan error, len(servers))
+	for _, server := range servers {
+		go func() {
+			if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				errs <- fmt.Errorf("server on %s failed: %w", server.Addr, err)
+			}
+		}()
 	}
 
-	if err := http.ListenAndServe(addr, handler); err != nil {
-		log.Fatalf("Server failed to start: %v", err)
+	var cause error
+	select {
+	case cause = <-errs:
+	case <-ctx.Done():
+		slog.Info("Shutting down, draining in-flight requests",
+			"drain_delay", cfg.ShutdownDrainDelay,
+			"timeout", cfg.ShutdownTimeout,
+		)
 	}
+	stop()
+
+	return shutdown(servers, checker,
+		time.Duration(cfg.ShutdownDrainDelay)*time.Second,
+		time.Duration(cfg.ShutdownTimeout)*time.Second,
+		cause,
+	)
 }
 
-func applyMiddleware(mux *http.ServeMux, cfg *configs.Config, rateLimiter *limiter.RateLimiter) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == "/health" {
-			mux.ServeHTTP(w, r)
-			return
+// shutdown marks the server as not ready and, when stopped by a signal,
+// keeps serving for drainDelay, so that load balancers see /readyz fail and
+// stop sending new requests. Then it stops accepting connections and waits
+// up to timeout for the in-flight requests to finish. cause, the error that
+// stopped the server if any, is returned along with any shutdown failure;
+// there is no point in waiting for the drain delay then.
+func shutdown(servers []*http.Server, checker *health.Checker, drainDelay, timeout time.Duration, cause error) error {
+	checker.Drain()
+	if cause == nil {
+		time.Sleep(drainDelay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	errs := []error{cause}
+	for _, server := range servers {
+		if err := server.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("shutting down server on %s: %w", server.Addr, err))
 		}
+	}
+	return errors.Join(errs...)
+}
 
-		rateLimiterMiddleware := middleware.RateLimiterMiddleware(cfg, rateLimiter)
-		rateLimiterMiddleware(mux).ServeHTTP(w, r)
-	})
+func applyMiddleware(mux *http.ServeMux, cfg configs.Provider, rateLimiter *limiter.RateLimiter, opts ...middleware.Option) http.Handler {
+	// Which routes are limited (or exempt, like /health) is decided by the
+	// configured rules
+	rateLimiterMiddleware := middleware.RateLimiterMiddleware(cfg, rateLimiter, opts...)
+	return rateLimiterMiddleware(mux)
+}
+
+func watchSIGHUP(reloader *configs.Reloader) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	for range signals {
+		if err := reloader.Reload(); err != nil {
+			slog.Error("Configuration reload rejected, keeping previous configuration", "error", err)
+			continue
+		}
+		slog.Info("Configuration reloaded on SIGHUP")
+	}
 }
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/health"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShutdown_ServesNotReadyDuringDrainDelay(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	defer store.Close()
+	checker := health.NewChecker(store, time.Second)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/readyz", checker.Readyz)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	server := &http.Server{Handler: mux}
+	go server.Serve(listener)
+
+	url := "http://" + listener.Addr().String()
+	client := &http.Client{Timeout: time.Second}
+	get := func(path string) (int, error) {
+		resp, err := client.Get(url + path)
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+
+	status, err := get("/readyz")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+
+	done := make(chan error, 1)
+	start := time.Now()
+	go func() {
+		done <- shutdown([]*http.Server{server}, checker, 300*time.Millisecond, time.Second, nil)
+	}()
+
+	// During the delay the server is not ready but still serves requests
+	assert.Eventually(t, func() bool {
+		status, err := get("/readyz")
+		return err == nil && status == http.StatusServiceUnavailable
+	}, 200*time.Millisecond, 10*time.Millisecond)
+
+	status, err = get("/")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+
+	// Then it shuts down
+	assert.NoError(t, <-done)
+	assert.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond)
+
+	_, err = get("/")
+	assert.Error(t, err)
+}
+
+func TestShutdown_ReturnsCause(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	defer store.Close()
+
+	// A failed listener does not wait for the drain delay
+	cause := errors.New("server on :8080 failed")
+	start := time.Now()
+	err := shutdown([]*http.Server{{}}, health.NewChecker(store, time.Second), time.Minute, time.Second, cause)
+	assert.ErrorIs(t, err, cause)
+	assert.Less(t, time.Since(start), time.Second)
+}
@@ -0,0 +1,111 @@
+package configs
+
+import (
+	"net/netip"
+	"sort"
+)
+
+// Access is the verdict of the allow and deny lists for a request
+type Access int
+
+const (
+	// AccessDefault means no list matched and the request is rate limited as usual
+	AccessDefault Access = iota
+
+	// AccessAllow means the request bypasses rate limiting entirely
+	AccessAllow
+
+	// AccessDeny means the request must be rejected with 403
+	AccessDeny
+)
+
+// CheckAccess matches the client address and raw token against the allow
+// and deny lists, which hold token hashes. Deny wins when the request is
+// on both lists.
+func (c *Config) CheckAccess(addr netip.Addr, token string) Access {
+	var hash string
+	if token != "" {
+		hash = HashToken(token)
+	}
+
+	if c.DeniedIPs.Contains(addr) || (hash != "" && c.DeniedTokens[hash]) {
+		return AccessDeny
+	}
+	if c.AllowedIPs.Contains(addr) || (hash != "" && c.AllowedTokens[hash]) {
+		return AccessAllow
+	}
+	return AccessDefault
+}
+
+// PrefixSet is an immutable set of IP prefixes. Prefixes are indexed by
+// length, so a lookup costs one map access per distinct prefix length
+// rather than one comparison per prefix. A nil set is empty.
+type PrefixSet struct {
+	prefixes map[netip.Prefix]struct{}
+
+	// Distinct prefix lengths of each family, longest first
+	v4Bits []int
+	v6Bits []int
+}
+
+func NewPrefixSet(prefixes []netip.Prefix) *PrefixSet {
+	s := &PrefixSet{prefixes: make(map[netip.Prefix]struct{}, len(prefixes))}
+
+	v4 := make(map[int]bool)
+	v6 := make(map[int]bool)
+	for _, prefix := range prefixes {
+		prefix = prefix.Masked()
+		s.prefixes[prefix] = struct{}{}
+
+		if prefix.Addr().Is4() {
+			v4[prefix.Bits()] = true
+		} else {
+			v6[prefix.Bits()] = true
+		}
+	}
+
+	s.v4Bits = sortedBits(v4)
+	s.v6Bits = sortedBits(v6)
+	return s
+}
+
+// Contains reports whether addr belongs to any prefix of the set
+func (s *PrefixSet) Contains(addr netip.Addr) bool {
+	if s == nil || !addr.IsValid() {
+		return false
+	}
+
+	addr = addr.Unmap().WithZone("")
+	bits := s.v4Bits
+	if addr.Is6() {
+		bits = s.v6Bits
+	}
+
+	for _, n := range bits {
+		prefix, err := addr.Prefix(n)
+		if err != nil {
+			continue
+		}
+		if _, ok := s.prefixes[prefix]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns the number of distinct prefixes in the set
+func (s *PrefixSet) Len() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.prefixes)
+}
+
+func sortedBits(set map[int]bool) []int {
+	bits := make([]int, 0, len(set))
+	for n := range set {
+		bits = append(bits, n)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(bits)))
+	return bits
+}
@@ -0,0 +1,60 @@
+package configs
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrefixSet_Contains(t *testing.T) {
+	set := NewPrefixSet([]netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("192.168.1.7/32"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	})
+
+	assert.Equal(t, 3, set.Len())
+	assert.True(t, set.Contains(netip.MustParseAddr("10.20.30.40")))
+	assert.True(t, set.Contains(netip.MustParseAddr("192.168.1.7")))
+	assert.True(t, set.Contains(netip.MustParseAddr("::ffff:10.0.0.1")))
+	assert.True(t, set.Contains(netip.MustParseAddr("2001:db8:ffff::1")))
+	assert.False(t, set.Contains(netip.MustParseAddr("192.168.1.8")))
+	assert.False(t, set.Contains(netip.MustParseAddr("11.0.0.1")))
+	assert.False(t, set.Contains(netip.MustParseAddr("2001:db9::1")))
+	assert.False(t, set.Contains(netip.Addr{}))
+
+	var empty *PrefixSet
+	assert.Equal(t, 0, empty.Len())
+	assert.False(t, empty.Contains(netip.MustParseAddr("10.0.0.1")))
+}
+
+func TestCheckAccess(t *testing.T) {
+	cfg := &Config{
+		AllowedIPs:    NewPrefixSet([]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}),
+		DeniedIPs:     NewPrefixSet([]netip.Prefix{netip.MustParsePrefix("10.6.6.0/24")}),
+		AllowedTokens: map[string]bool{HashToken("partner"): true},
+		DeniedTokens:  map[string]bool{HashToken("revoked"): true},
+	}
+
+	tests := []struct {
+		addr     string
+		token    string
+		expected Access
+	}{
+		{"192.168.1.1", "", AccessDefault},
+		{"10.1.1.1", "", AccessAllow},
+		{"10.6.6.6", "", AccessDeny},
+		{"192.168.1.1", "partner", AccessAllow},
+		{"192.168.1.1", "revoked", AccessDeny},
+		{"10.1.1.1", "revoked", AccessDeny},
+		{"10.6.6.6", "partner", AccessDeny},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, cfg.CheckAccess(netip.MustParseAddr(tt.addr), tt.token), "%s %s", tt.addr, tt.token)
+	}
+
+	// A configuration without lists limits everyone
+	assert.Equal(t, AccessDefault, (&Config{}).CheckAccess(netip.MustParseAddr("10.1.1.1"), "partner"))
+}
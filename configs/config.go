@@ -2,57 +2,413 @@ package configs
 
 import (
 	"fmt"
+	"net/netip"
 	"os"
-	"strconv"
+	"sort"
 	"strings"
+	"time"
+
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/jwt"
 )
 
 type Config struct {
+	// Path of the optional YAML/JSON configuration file and how often (in
+	// seconds) it is checked for changes; 0 disables the file watcher
+	ConfigFile           string
+	ConfigReloadInterval int
+
 	RedisHost     string
 	RedisPort     string
 	RedisPassword string
 	RedisDB       int
 	ServerPort    string
 
+	// How long (in seconds) in-flight requests are given to finish once a
+	// shutdown signal is received, and how long the server keeps accepting
+	// requests before that while /readyz reports it as shutting down
+	ShutdownTimeout    int
+	ShutdownDrainDelay int
+
+	// Admin API (disabled when AdminToken is empty)
+	AdminPort  string
+	AdminToken string
+
+	// Port of the Prometheus /metrics endpoint, kept off the public port
+	MetricsPort string
+
 	// Default rate limits
 	RateLimitIP             int
 	RateLimitIPBlockTime    int
 	RateLimitToken          int
 	RateLimitTokenBlockTime int
 
-	// Token-specific configurations
+	// Token-specific configurations, indexed by the SHA-256 hash of the
+	// token (see HashToken), and the optional token registry file they are
+	// also loaded from
 	TokenConfigs map[string]TokenConfig
+	TokensFile   string
+
+	// Named token tiers (only available through the configuration file)
+	Tiers map[string]TokenConfig
+
+	// Route-specific rules (only available through the configuration file)
+	// and how the rule applied to a request is chosen
+	Rules          []Rule
+	RulePrecedence string
+
+	// Which rate-limit headers are sent with every response
+	RateLimitHeaders string
+
+	// Log output format and level, and the fraction (0 to 1) of rate limit
+	// decisions that are logged; 0 disables decision logs
+	LogFormat         string
+	LogLevel          string
+	DecisionLogSample float64
+
+	// Where trace spans are exported (TracingNone, TracingStdout or
+	// TracingOTLP), the OTLP/HTTP collector endpoint and the service name
+	// they are reported under
+	TracingExporter    string
+	TracingEndpoint    string
+	TracingServiceName string
+
+	// In shadow mode limits are evaluated and counted but requests that
+	// would be rejected are let through (rules can also enable it one by one)
+	ShadowMode bool
+
+	// Response sent to rate limited requests (built-in JSON body by default)
+	Rejection *RejectionResponse
+
+	// Reverse proxy allowed requests are forwarded to; nil unless an
+	// upstream is configured, in which case the server runs as a gateway
+	Gateway *Gateway
+
+	// How API tokens that are neither configured nor registered are handled
+	UnknownTokenPolicy string
+
+	// Limits enforced along with the limit of a token (see CombinedIP and
+	// CombinedTokenIP) and the per-token-per-IP limit
+	CombinedLimits            map[string]bool
+	RateLimitTokenIP          int
+	RateLimitTokenIPBlockTime int
+
+	// Where the client IP is taken from, and which proxies are trusted to
+	// report it through forwarding headers
+	ClientIPSource string
+	TrustedProxies []netip.Prefix
+
+	// Prefix lengths client addresses are aggregated to before limiting
+	IPv4Prefix int
+	IPv6Prefix int
+
+	// Access lists checked before any limit: denied clients are rejected
+	// with 403 and allowed ones are never rate limited
+	AllowedIPs    *PrefixSet
+	DeniedIPs     *PrefixSet
+	AllowedTokens map[string]bool
+	DeniedTokens  map[string]bool
+
+	// Bearer JWT identities: JWTVerifier is nil unless HS256 secrets or a
+	// JWKS file are configured. JWTKeyClaim identifies the client and
+	// JWTTierClaim, when set, selects one of the Tiers.
+	JWTVerifier  *jwt.Verifier
+	JWTKeyClaim  string
+	JWTTierClaim string
 }
 
+// Rate-limit header modes
+const (
+	// HeadersLegacy sends X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset
+	HeadersLegacy = "legacy"
+
+	// HeadersDraft sends the IETF draft RateLimit and RateLimit-Policy headers
+	HeadersDraft = "draft"
+
+	// HeadersBoth sends both sets of headers
+	HeadersBoth = "both"
+
+	// HeadersNone sends no rate-limit headers (Retry-After is always sent on 429)
+	HeadersNone = "none"
+)
+
+// Log formats
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// Trace exporters
+const (
+	TracingNone   = "none"
+	TracingStdout = "stdout"
+	TracingOTLP   = "otlp"
+)
+
+// Unknown token policies
+const (
+	// UnknownTokenDefault limits unknown tokens like known ones, with the
+	// default tier (the behavior of configurations that do not set a policy)
+	UnknownTokenDefault = "default"
+
+	// UnknownTokenReject rejects requests with unknown tokens with 401
+	UnknownTokenReject = "reject"
+
+	// UnknownTokenIP ignores unknown tokens and limits the request by IP
+	UnknownTokenIP = "ip"
+
+	// UnknownTokenUnregistered limits unknown tokens by IP with the limits of
+	// the "unregistered" tier, apart from the regular IP counters
+	UnknownTokenUnregistered = "unregistered"
+)
+
+// Combined limits
+const (
+	// CombinedIP also counts requests limited by token against the limit of
+	// their client IP, so one address cycling through tokens is contained
+	CombinedIP = "ip"
+
+	// CombinedTokenIP also limits each token per client IP, so a leaked
+	// token used from many addresses is contained
+	CombinedTokenIP = "token-ip"
+)
+
+// UnregisteredTier is the tier of unknown tokens under UnknownTokenUnregistered
+const UnregisteredTier = "unregistered"
+
+// TokenConfig holds the limits of a token or tier
 type TokenConfig struct {
 	Limit     int
 	BlockTime int
+
+	// Window is the counting window in seconds (1 by default). Quota, when
+	// positive, caps the requests over QuotaWindow seconds (a day by default).
+	Window      int
+	Quota       int
+	QuotaWindow int
+
+	// Tier is the name of the tier the limits come from and Name the name
+	// the token was declared with, if any
+	Tier string
+	Name string
+}
+
+// DefaultTier is the fallback tier of tokens that are not assigned to one.
+// Its limits are RATE_LIMIT_TOKEN and RATE_LIMIT_TOKEN_BLOCK_TIME; declaring
+// a "default" tier in the file can also give it a window and a quota.
+const DefaultTier = "default"
+
+// WindowDuration returns the counting window (1 second by default)
+func (tc TokenConfig) WindowDuration() time.Duration {
+	if tc.Window <= 0 {
+		return 1 * time.Second
+	}
+	return time.Duration(tc.Window) * time.Second
 }
 
+// BlockDuration returns how long a token is blocked after exceeding its limit
+func (tc TokenConfig) BlockDuration() time.Duration {
+	return time.Duration(tc.BlockTime) * time.Second
+}
+
+// QuotaDuration returns the quota window (a day by default)
+func (tc TokenConfig) QuotaDuration() time.Duration {
+	if tc.QuotaWindow <= 0 {
+		return 24 * time.Hour
+	}
+	return time.Duration(tc.QuotaWindow) * time.Second
+}
+
+// LoadConfig builds the configuration. Values are resolved in the following
+// order, each one overriding the previous: built-in defaults, the file
+// pointed to by CONFIG_FILE and finally environment variables.
+//
+// Every problem found while loading (unparsable numbers, non-positive
+// limits, inconsistent tokens...) is collected and returned together as a
+// *ValidationError.
 func LoadConfig() (*Config, error) {
+	base := defaultConfig()
+	base.ConfigFile = getEnv("CONFIG_FILE", "")
+
+	var fc *fileConfig
+	if base.ConfigFile != "" {
+		var err error
+		fc, err = loadFile(base.ConfigFile)
+		if err != nil {
+			return nil, err
+		}
+		fc.apply(base)
+	}
+
+	l := &loader{}
+
 	cfg := &Config{
-		RedisHost:     getEnv("REDIS_HOST", "localhost"),
-		RedisPort:     getEnv("REDIS_PORT", "6379"),
-		RedisPassword: getEnv("REDIS_PASSWORD", ""),
-		RedisDB:       getEnvAsInt("REDIS_DB", 0),
-		ServerPort:    getEnv("SERVER_PORT", "8080"),
+		ConfigFile:           base.ConfigFile,
+		ConfigReloadInterval: l.envInt("CONFIG_RELOAD_INTERVAL", 5),
+
+		RedisHost:     getEnv("REDIS_HOST", base.RedisHost),
+		RedisPort:     getEnv("REDIS_PORT", base.RedisPort),
+		RedisPassword: getEnv("REDIS_PASSWORD", base.RedisPassword),
+		RedisDB:       l.envInt("REDIS_DB", base.RedisDB),
+		ServerPort:    getEnv("SERVER_PORT", base.ServerPort),
+
+		ShutdownTimeout:    l.envInt("SHUTDOWN_TIMEOUT", base.ShutdownTimeout),
+		ShutdownDrainDelay: l.envInt("SHUTDOWN_DRAIN_DELAY", base.ShutdownDrainDelay),
+
+		AdminPort:  getEnv("ADMIN_PORT", base.AdminPort),
+		AdminToken: getEnv("ADMIN_TOKEN", base.AdminToken),
 
-		RateLimitIP:             getEnvAsInt("RATE_LIMIT_IP", 10),
-		RateLimitIPBlockTime:    getEnvAsInt("RATE_LIMIT_IP_BLOCK_TIME", 300),
-		RateLimitToken:          getEnvAsInt("RATE_LIMIT_TOKEN", 100),
-		RateLimitTokenBlockTime: getEnvAsInt("RATE_LIMIT_TOKEN_BLOCK_TIME", 300),
+		MetricsPort: getEnv("METRICS_PORT", base.MetricsPort),
+
+		RateLimitIP:             l.envInt("RATE_LIMIT_IP", base.RateLimitIP),
+		RateLimitIPBlockTime:    l.envInt("RATE_LIMIT_IP_BLOCK_TIME", base.RateLimitIPBlockTime),
+		RateLimitToken:          l.envInt("RATE_LIMIT_TOKEN", base.RateLimitToken),
+		RateLimitTokenBlockTime: l.envInt("RATE_LIMIT_TOKEN_BLOCK_TIME", base.RateLimitTokenBlockTime),
+
+		RateLimitTokenIP:          l.envInt("RATE_LIMIT_TOKEN_IP", base.RateLimitTokenIP),
+		RateLimitTokenIPBlockTime: l.envInt("RATE_LIMIT_TOKEN_IP_BLOCK_TIME", base.RateLimitTokenIPBlockTime),
 
 		TokenConfigs: make(map[string]TokenConfig),
+		TokensFile:   getEnv("TOKENS_FILE", base.TokensFile),
+		Tiers:        base.Tiers,
+		Rules:        base.Rules,
+
+		RulePrecedence: getEnv("RULE_PRECEDENCE", base.RulePrecedence),
+
+		RateLimitHeaders: getEnv("RATE_LIMIT_HEADERS", base.RateLimitHeaders),
+		ShadowMode:       l.envBool("SHADOW_MODE", base.ShadowMode),
+
+		LogFormat:         getEnv("LOG_FORMAT", base.LogFormat),
+		LogLevel:          getEnv("LOG_LEVEL", base.LogLevel),
+		DecisionLogSample: l.envFloat("DECISION_LOG_SAMPLE", base.DecisionLogSample),
+
+		TracingExporter:    getEnv("TRACING_EXPORTER", base.TracingExporter),
+		TracingEndpoint:    getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", base.TracingEndpoint),
+		TracingServiceName: getEnv("OTEL_SERVICE_NAME", base.TracingServiceName),
+
+		UnknownTokenPolicy: getEnv("UNKNOWN_TOKEN_POLICY", base.UnknownTokenPolicy),
+
+		ClientIPSource: getEnv("CLIENT_IP_SOURCE", base.ClientIPSource),
+		IPv4Prefix:     l.envInt("IP_V4_PREFIX", base.IPv4Prefix),
+		IPv6Prefix:     l.envInt("IP_V6_PREFIX", base.IPv6Prefix),
+
+		JWTKeyClaim:  getEnv("JWT_KEY_CLAIM", base.JWTKeyClaim),
+		JWTTierClaim: getEnv("JWT_TIER_CLAIM", base.JWTTierClaim),
+	}
+
+	var trustedProxies []string
+	if fc != nil {
+		trustedProxies = fc.ClientIP.TrustedProxies
+	}
+	cfg.TrustedProxies = l.envPrefixes("TRUSTED_PROXIES", trustedProxies)
+
+	var access fileAccess
+	if fc != nil {
+		access = fc.Access
+	}
+	cfg.AllowedIPs = NewPrefixSet(l.envPrefixes("ALLOW_IPS", access.Allow.IPs))
+	cfg.DeniedIPs = NewPrefixSet(l.envPrefixes("DENY_IPS", access.Deny.IPs))
+	cfg.AllowedTokens = l.tokenSet("ALLOW_TOKENS", envSet("ALLOW_TOKENS", access.Allow.Tokens))
+	cfg.DeniedTokens = l.tokenSet("DENY_TOKENS", envSet("DENY_TOKENS", access.Deny.Tokens))
+
+	var combinedLimits []string
+	if fc != nil {
+		combinedLimits = fc.CombinedLimits
+	}
+	cfg.CombinedLimits = envSet("COMBINED_LIMITS", combinedLimits)
+
+	cfg.loadJWT(l, fc)
+	cfg.loadRejection(l, fc)
+	cfg.loadGateway(l, fc)
+
+	// The default token limits are the fallback tier
+	fallback := cfg.Tiers[DefaultTier]
+	fallback.Limit = cfg.RateLimitToken
+	fallback.BlockTime = cfg.RateLimitTokenBlockTime
+	cfg.Tiers[DefaultTier] = fallback
+
+	// Tokens from the files are resolved after the environment so that they
+	// inherit overridden defaults; TOKEN_* variables still win over them.
+	if fc != nil {
+		for hash, tc := range resolveTokens("", fc.Tokens, cfg, l) {
+			cfg.TokenConfigs[hash] = tc
+		}
+	}
+	if cfg.TokensFile != "" {
+		declared, err := loadTokensFile(cfg.TokensFile)
+		if err != nil {
+			l.addf("TOKENS_FILE: %v", err)
+		}
+		for hash, tc := range resolveTokens("TOKENS_FILE", declared, cfg, l) {
+			cfg.TokenConfigs[hash] = tc
+		}
 	}
 
 	// Load token-specific configurations
-	cfg.loadTokenConfigs()
+	cfg.loadTokenConfigs(l)
+
+	l.problems = append(l.problems, cfg.validate()...)
+	if len(l.problems) > 0 {
+		return nil, &ValidationError{Problems: l.problems}
+	}
+
+	// Rule keys are parsed once rather than on every request
+	for i := range cfg.Rules {
+		cfg.Rules[i].keyParts = cfg.Rules[i].ParsedKey()
+	}
 
 	return cfg, nil
 }
 
-func (c *Config) loadTokenConfigs() {
+func defaultConfig() *Config {
+	return &Config{
+		RedisHost:  "localhost",
+		RedisPort:  "6379",
+		RedisDB:    0,
+		ServerPort: "8080",
+
+		ShutdownTimeout:    15,
+		ShutdownDrainDelay: 5,
+
+		AdminPort: "9090",
+
+		MetricsPort: "9091",
+
+		RateLimitIP:             10,
+		RateLimitIPBlockTime:    300,
+		RateLimitToken:          100,
+		RateLimitTokenBlockTime: 300,
+
+		RateLimitTokenIP:          20,
+		RateLimitTokenIPBlockTime: 300,
+
+		TokenConfigs: make(map[string]TokenConfig),
+		Tiers:        make(map[string]TokenConfig),
+
+		RulePrecedence: PrecedenceFirstMatch,
+
+		RateLimitHeaders: HeadersLegacy,
+
+		LogFormat: LogFormatText,
+		LogLevel:  "info",
+
+		TracingExporter:    TracingNone,
+		TracingEndpoint:    "http://localhost:4318",
+		TracingServiceName: "rate-limiter",
+
+		// Random tokens must not escape the IP limits
+		UnknownTokenPolicy: UnknownTokenIP,
+
+		ClientIPSource: ClientIPAuto,
+		IPv4Prefix:     32,
+		IPv6Prefix:     64,
+
+		JWTKeyClaim: "sub",
+	}
+}
+
+func (c *Config) loadTokenConfigs(l *loader) {
 	tokens := make(map[string]string)
+	settings := make(map[string]string)
 
 	// First pass: find all TOKEN_* entries
 	for _, env := range os.Environ() {
@@ -64,41 +420,119 @@ func (c *Config) loadTokenConfigs() {
 		key := pair[0]
 		value := pair[1]
 
-		// Check if it's a TOKEN_{NAME} entry (not _LIMIT or _BLOCK_TIME)
-		if strings.HasPrefix(key, "TOKEN_") &&
-			!strings.HasSuffix(key, "_LIMIT") &&
-			!strings.HasSuffix(key, "_BLOCK_TIME") {
+		if !strings.HasPrefix(key, "TOKEN_") {
+			continue
+		}
+
+		// Check if it's a TOKEN_{NAME} entry (not _LIMIT, _BLOCK_TIME or _TIER)
+		if !strings.HasSuffix(key, "_LIMIT") && !strings.HasSuffix(key, "_BLOCK_TIME") && !strings.HasSuffix(key, "_TIER") {
 			// Extract the token name (e.g., "ONE" from "TOKEN_ONE")
 			tokenName := strings.TrimPrefix(key, "TOKEN_")
 			tokens[tokenName] = value
+			continue
+		}
+
+		name := strings.TrimPrefix(key, "TOKEN_")
+		for _, suffix := range []string{"_LIMIT", "_BLOCK_TIME", "_TIER"} {
+			name = strings.TrimSuffix(name, suffix)
+		}
+		settings[key] = name
+	}
+
+	// Settings must belong to a declared token
+	for _, key := range sortedKeys(settings) {
+		if _, ok := tokens[settings[key]]; !ok {
+			l.addf("%s is set but TOKEN_%s is not", key, settings[key])
 		}
 	}
 
 	// Second pass: for each token found, get its limit and block time
-	for tokenName, tokenValue := range tokens {
+	seen := make(map[string]string)
+	for _, tokenName := range sortedKeys(tokens) {
+		tokenValue := tokens[tokenName]
+
+		if tokenValue == "" {
+			l.addf("TOKEN_%s is empty", tokenName)
+			continue
+		}
+		hash, err := tokenHash(tokenValue)
+		if err != nil {
+			l.addf("TOKEN_%s %v", tokenName, err)
+			continue
+		}
+		if other, ok := seen[hash]; ok {
+			l.addf("TOKEN_%s and TOKEN_%s have the same value", other, tokenName)
+			continue
+		}
+		seen[hash] = tokenName
+
 		limitKey := fmt.Sprintf("TOKEN_%s_LIMIT", tokenName)
 		blockTimeKey := fmt.Sprintf("TOKEN_%s_BLOCK_TIME", tokenName)
+		tierKey := fmt.Sprintf("TOKEN_%s_TIER", tokenName)
+
+		// A token also declared in the file inherits its limits from there,
+		// unless it is assigned to a tier
+		defaults, ok := c.TokenConfigs[hash]
+		if tier := os.Getenv(tierKey); tier != "" {
+			if defaults, ok = c.GetTierConfig(tier); !ok {
+				l.addf("%s refers to unknown tier %q", tierKey, tier)
+			}
+		} else if !ok {
+			defaults, _ = c.GetTierConfig(DefaultTier)
+		}
 
-		limit := getEnvAsInt(limitKey, c.RateLimitToken)
-		blockTime := getEnvAsInt(blockTimeKey, c.RateLimitTokenBlockTime)
+		limit := l.envInt(limitKey, defaults.Limit)
+		blockTime := l.envInt(blockTimeKey, defaults.BlockTime)
 
-		c.TokenConfigs[tokenValue] = TokenConfig{
-			Limit:     limit,
-			BlockTime: blockTime,
+		// Inherited values are already checked where they are declared
+		if os.Getenv(limitKey) != "" {
+			l.positive(limitKey, limit)
 		}
+		if os.Getenv(blockTimeKey) != "" {
+			l.positive(blockTimeKey, blockTime)
+		}
+
+		defaults.Name = tokenName
+		defaults.Limit = limit
+		defaults.BlockTime = blockTime
+		c.TokenConfigs[hash] = defaults
 	}
 }
 
+// GetTokenConfig returns the limits of a raw token, as sent by a client
 func (c *Config) GetTokenConfig(token string) (TokenConfig, bool) {
-	cfg, exists := c.TokenConfigs[token]
+	return c.GetTokenConfigByHash(HashToken(token))
+}
+
+// GetTokenConfigByHash returns the limits of a token given its hash
+func (c *Config) GetTokenConfigByHash(hash string) (TokenConfig, bool) {
+	cfg, exists := c.TokenConfigs[hash]
+	if !exists {
+		// Unknown tokens get the fallback tier
+		cfg, _ = c.GetTierConfig(DefaultTier)
+		return cfg, false
+	}
+	return cfg, true
+}
+
+// GetTierConfig returns the limits of a tier, falling back to the default
+// tier for unknown tiers
+func (c *Config) GetTierConfig(tier string) (TokenConfig, bool) {
+	cfg, exists := c.Tiers[tier]
+	if exists {
+		cfg.Tier = tier
+		return cfg, true
+	}
+
+	cfg, exists = c.Tiers[DefaultTier]
 	if !exists {
-		// Return default token configuration
-		return TokenConfig{
+		cfg = TokenConfig{
 			Limit:     c.RateLimitToken,
 			BlockTime: c.RateLimitTokenBlockTime,
-		}, false
+		}
 	}
-	return cfg, true
+	cfg.Tier = DefaultTier
+	return cfg, false
 }
 
 func getEnv(key, defaultValue string) string {
@@ -108,16 +542,11 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
-func getEnvAsInt(key string, defaultValue int) int {
-	valueStr := os.Getenv(key)
-	if valueStr == "" {
-		return defaultValue
-	}
-
-	value, err := strconv.Atoi(valueStr)
-	if err != nil {
-		return defaultValue
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
 	}
-
-	return value
+	sort.Strings(keys)
+	return keys
 }
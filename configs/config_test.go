@@ -1,8 +1,12 @@
 package configs
 
 import (
+	"net/netip"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -84,13 +88,13 @@ func TestLoadConfig_TokenConfigs(t *testing.T) {
 	assert.NotNil(t, cfg)
 
 	// Check premium token config (using actual token value as key)
-	premiumCfg, exists := cfg.TokenConfigs["premium-token-123"]
+	premiumCfg, exists := cfg.TokenConfigs[HashToken("premium-token-123")]
 	assert.True(t, exists)
 	assert.Equal(t, 1000, premiumCfg.Limit)
 	assert.Equal(t, 120, premiumCfg.BlockTime)
 
 	// Check basic token config (using actual token value as key)
-	basicCfg, exists := cfg.TokenConfigs["basic-token-456"]
+	basicCfg, exists := cfg.TokenConfigs[HashToken("basic-token-456")]
 	assert.True(t, exists)
 	assert.Equal(t, 50, basicCfg.Limit)
 	assert.Equal(t, 600, basicCfg.BlockTime)
@@ -137,19 +141,1039 @@ func TestGetEnv(t *testing.T) {
 	assert.Equal(t, "default", value)
 }
 
-func TestGetEnvAsInt(t *testing.T) {
+func TestLoaderEnvInt(t *testing.T) {
 	os.Setenv("TEST_INT", "42")
 	defer os.Unsetenv("TEST_INT")
 
-	value := getEnvAsInt("TEST_INT", 10)
+	l := &loader{}
+
+	value := l.envInt("TEST_INT", 10)
 	assert.Equal(t, 42, value)
 
-	value = getEnvAsInt("NON_EXISTENT", 10)
+	value = l.envInt("NON_EXISTENT", 10)
 	assert.Equal(t, 10, value)
+	assert.Empty(t, l.problems)
 
 	os.Setenv("INVALID_INT", "not-a-number")
 	defer os.Unsetenv("INVALID_INT")
 
-	value = getEnvAsInt("INVALID_INT", 10)
+	value = l.envInt("INVALID_INT", 10)
 	assert.Equal(t, 10, value)
+	assert.Equal(t, []string{`INVALID_INT: "not-a-number" is not a valid integer`}, l.problems)
+}
+
+func TestLoadConfig_ValidationErrors(t *testing.T) {
+	os.Setenv("RATE_LIMIT_IP", "ten")
+	os.Setenv("RATE_LIMIT_IP_BLOCK_TIME", "0")
+	os.Setenv("RATE_LIMIT_TOKEN", "-5")
+	os.Setenv("TOKEN_A", "same-value")
+	os.Setenv("TOKEN_B", "same-value")
+	os.Setenv("TOKEN_C", "c-value")
+	os.Setenv("TOKEN_C_LIMIT", "0")
+	os.Setenv("TOKEN_ORPHAN_LIMIT", "10")
+
+	defer func() {
+		os.Unsetenv("RATE_LIMIT_IP")
+		os.Unsetenv("RATE_LIMIT_IP_BLOCK_TIME")
+		os.Unsetenv("RATE_LIMIT_TOKEN")
+		os.Unsetenv("TOKEN_A")
+		os.Unsetenv("TOKEN_B")
+		os.Unsetenv("TOKEN_C")
+		os.Unsetenv("TOKEN_C_LIMIT")
+		os.Unsetenv("TOKEN_ORPHAN_LIMIT")
+	}()
+
+	cfg, err := LoadConfig()
+	assert.Nil(t, cfg)
+
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.ElementsMatch(t, []string{
+		`RATE_LIMIT_IP: "ten" is not a valid integer`,
+		"TOKEN_ORPHAN_LIMIT is set but TOKEN_ORPHAN is not",
+		"TOKEN_A and TOKEN_B have the same value",
+		"TOKEN_C_LIMIT must be positive, got 0",
+		"RATE_LIMIT_IP_BLOCK_TIME must be positive, got 0",
+		"RATE_LIMIT_TOKEN must be positive, got -5",
+	}, validationErr.Problems)
+
+	// The report lists every problem
+	assert.Contains(t, err.Error(), "invalid configuration (6 problems)")
+	assert.Contains(t, err.Error(), "TOKEN_ORPHAN_LIMIT is set but TOKEN_ORPHAN is not")
+}
+
+func TestLoadConfig_FileValidationErrors(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+tiers:
+  broken:
+    limit: 0
+    block_time: 10
+tokens:
+  - name: ONE
+    value: abc123
+    tier: missing
+  - name: TWO
+    value: abc123
+rules:
+  - name: login
+    limit: 5
+    block_time: 60
+`)
+	os.Setenv("CONFIG_FILE", path)
+	defer os.Unsetenv("CONFIG_FILE")
+
+	_, err := LoadConfig()
+
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.ElementsMatch(t, []string{
+		`token "ONE" refers to unknown tier "missing"`,
+		`token "ONE" and token "TWO" have the same value`,
+		`tier "broken" limit must be positive, got 0`,
+		`rule "login" must have a path`,
+	}, validationErr.Problems)
+}
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	err := os.WriteFile(path, []byte(content), 0o600)
+	assert.NoError(t, err)
+	return path
+}
+
+func TestLoadConfig_YAMLFile(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+server:
+  port: "7070"
+storage:
+  redis:
+    host: file-redis
+    db: 2
+defaults:
+  ip:
+    limit: 15
+  token:
+    limit: 150
+    block_time: 60
+tiers:
+  premium:
+    limit: 1000
+    block_time: 30
+tokens:
+  - name: PREMIUM
+    value: premium-token
+    tier: premium
+  - name: CUSTOM
+    value: custom-token
+    tier: premium
+    limit: 5
+  - name: PLAIN
+    value: plain-token
+rules:
+  - name: login
+    path: /login
+    methods: [POST]
+    limit: 3
+    block_time: 600
+`)
+	os.Setenv("CONFIG_FILE", path)
+	defer os.Unsetenv("CONFIG_FILE")
+
+	cfg, err := LoadConfig()
+	assert.NoError(t, err)
+
+	assert.Equal(t, path, cfg.ConfigFile)
+	assert.Equal(t, "7070", cfg.ServerPort)
+	assert.Equal(t, "file-redis", cfg.RedisHost)
+	assert.Equal(t, "6379", cfg.RedisPort)
+	assert.Equal(t, 2, cfg.RedisDB)
+	assert.Equal(t, 15, cfg.RateLimitIP)
+	assert.Equal(t, 300, cfg.RateLimitIPBlockTime)
+	assert.Equal(t, 150, cfg.RateLimitToken)
+
+	assert.Equal(t, TokenConfig{Limit: 1000, BlockTime: 30, Tier: "premium", Name: "PREMIUM"}, cfg.TokenConfigs[HashToken("premium-token")])
+	assert.Equal(t, TokenConfig{Limit: 5, BlockTime: 30, Tier: "premium", Name: "CUSTOM"}, cfg.TokenConfigs[HashToken("custom-token")])
+	assert.Equal(t, TokenConfig{Limit: 150, BlockTime: 60, Tier: DefaultTier, Name: "PLAIN"}, cfg.TokenConfigs[HashToken("plain-token")])
+
+	assert.Len(t, cfg.Rules, 1)
+	assert.Equal(t, "/login", cfg.Rules[0].Path)
+	assert.Equal(t, []string{"POST"}, cfg.Rules[0].Methods)
+}
+
+func TestLoadConfig_JSONFile(t *testing.T) {
+	path := writeConfigFile(t, "config.json", `{
+		"defaults": {"ip": {"limit": 25, "block_time": 120}},
+		"tokens": [{"name": "ONE", "value": "abc123", "limit": 50}]
+	}`)
+	os.Setenv("CONFIG_FILE", path)
+	defer os.Unsetenv("CONFIG_FILE")
+
+	cfg, err := LoadConfig()
+	assert.NoError(t, err)
+
+	assert.Equal(t, 25, cfg.RateLimitIP)
+	assert.Equal(t, 120, cfg.RateLimitIPBlockTime)
+	assert.Equal(t, 50, cfg.TokenConfigs[HashToken("abc123")].Limit)
+}
+
+func TestLoadConfig_EnvOverridesFile(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+defaults:
+  ip:
+    limit: 15
+  token:
+    limit: 150
+tokens:
+  - name: ONE
+    value: abc123
+`)
+	os.Setenv("CONFIG_FILE", path)
+	os.Setenv("RATE_LIMIT_IP", "30")
+	os.Setenv("RATE_LIMIT_TOKEN", "300")
+	os.Setenv("TOKEN_ONE", "abc123")
+	os.Setenv("TOKEN_ONE_LIMIT", "42")
+
+	defer func() {
+		os.Unsetenv("CONFIG_FILE")
+		os.Unsetenv("RATE_LIMIT_IP")
+		os.Unsetenv("RATE_LIMIT_TOKEN")
+		os.Unsetenv("TOKEN_ONE")
+		os.Unsetenv("TOKEN_ONE_LIMIT")
+	}()
+
+	cfg, err := LoadConfig()
+	assert.NoError(t, err)
+
+	assert.Equal(t, 30, cfg.RateLimitIP)
+	assert.Equal(t, 300, cfg.RateLimitToken)
+	assert.Equal(t, 42, cfg.TokenConfigs[HashToken("abc123")].Limit)
+}
+
+func TestLoadConfig_InvalidFile(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "defaults:\n  unknown_field: 1\n")
+	os.Setenv("CONFIG_FILE", path)
+	defer os.Unsetenv("CONFIG_FILE")
+
+	_, err := LoadConfig()
+	assert.Error(t, err)
+
+	os.Setenv("CONFIG_FILE", filepath.Join(t.TempDir(), "missing.yaml"))
+	_, err = LoadConfig()
+	assert.Error(t, err)
+}
+
+func TestLoadConfig_RuleValidation(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+rule_precedence: best
+rules:
+  - path: /a
+    limit: 1
+    block_time: 1
+  - name: b
+    path: /b
+    limit: 1
+    block_time: 1
+    key: cookie
+  - name: b
+    path: /c
+    exempt: true
+`)
+	os.Setenv("CONFIG_FILE", path)
+	defer os.Unsetenv("CONFIG_FILE")
+
+	_, err := LoadConfig()
+
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.ElementsMatch(t, []string{
+		`RULE_PRECEDENCE must be "first-match" or "most-specific", got "best"`,
+		"rule #1 must have a name",
+		`rule "b" has invalid key strategy "cookie": key part "cookie" needs a name, e.g. "cookie:name"`,
+		`rule "b" is declared more than once`,
+	}, validationErr.Problems)
+}
+
+func TestLoadConfig_ClientIP(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+client_ip:
+  source: x-forwarded-for
+  trusted_proxies: [10.0.0.0/8, 192.168.1.1]
+`)
+	os.Setenv("CONFIG_FILE", path)
+	defer os.Unsetenv("CONFIG_FILE")
+
+	cfg, err := LoadConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, ClientIPXForwardedFor, cfg.ClientIPSource)
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("192.168.1.1/32"),
+	}, cfg.TrustedProxies)
+	assert.True(t, cfg.IsTrustedProxy(netip.MustParseAddr("10.1.2.3")))
+	assert.False(t, cfg.IsTrustedProxy(netip.MustParseAddr("192.168.1.2")))
+
+	// The environment replaces the whole list
+	os.Setenv("TRUSTED_PROXIES", "172.16.0.0/12, ::1")
+	defer os.Unsetenv("TRUSTED_PROXIES")
+
+	cfg, err = LoadConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("172.16.0.0/12"),
+		netip.MustParsePrefix("::1/128"),
+	}, cfg.TrustedProxies)
+
+	os.Setenv("TRUSTED_PROXIES", "10.0.0.0/33,not-an-ip")
+	os.Setenv("CLIENT_IP_SOURCE", "cookie")
+	defer os.Unsetenv("CLIENT_IP_SOURCE")
+
+	_, err = LoadConfig()
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.ElementsMatch(t, []string{
+		`TRUSTED_PROXIES: "10.0.0.0/33" is not a valid IP address or CIDR`,
+		`TRUSTED_PROXIES: "not-an-ip" is not a valid IP address or CIDR`,
+		`CLIENT_IP_SOURCE must be one of "auto", "forwarded", "x-forwarded-for", "x-real-ip" or "remote-addr", got "cookie"`,
+	}, validationErr.Problems)
+}
+
+func TestLoadConfig_IPPrefixes(t *testing.T) {
+	os.Clearenv()
+
+	cfg, err := LoadConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, 32, cfg.IPv4Prefix)
+	assert.Equal(t, 64, cfg.IPv6Prefix)
+
+	os.Setenv("IP_V4_PREFIX", "33")
+	os.Setenv("IP_V6_PREFIX", "0")
+	defer func() {
+		os.Unsetenv("IP_V4_PREFIX")
+		os.Unsetenv("IP_V6_PREFIX")
+	}()
+
+	_, err = LoadConfig()
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.ElementsMatch(t, []string{
+		"IP_V4_PREFIX must be between 1 and 32, got 33",
+		"IP_V6_PREFIX must be between 1 and 128, got 0",
+	}, validationErr.Problems)
+}
+
+func TestLoadConfig_UnknownTokenPolicy(t *testing.T) {
+	os.Clearenv()
+
+	cfg, err := LoadConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, UnknownTokenIP, cfg.UnknownTokenPolicy)
+
+	path := writeConfigFile(t, "config.yaml", `
+unknown_tokens: unregistered
+tiers:
+  unregistered:
+    limit: 5
+    block_time: 60
+`)
+	os.Setenv("CONFIG_FILE", path)
+	defer os.Unsetenv("CONFIG_FILE")
+
+	cfg, err = LoadConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, UnknownTokenUnregistered, cfg.UnknownTokenPolicy)
+
+	os.Unsetenv("CONFIG_FILE")
+	os.Setenv("UNKNOWN_TOKEN_POLICY", "unregistered")
+	defer os.Unsetenv("UNKNOWN_TOKEN_POLICY")
+
+	_, err = LoadConfig()
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []string{`UNKNOWN_TOKEN_POLICY "unregistered" requires an "unregistered" tier`}, validationErr.Problems)
+
+	os.Setenv("UNKNOWN_TOKEN_POLICY", "allow")
+
+	_, err = LoadConfig()
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []string{
+		`UNKNOWN_TOKEN_POLICY must be one of "default", "reject", "ip" or "unregistered", got "allow"`,
+	}, validationErr.Problems)
+}
+
+func TestLoadConfig_CombinedLimits(t *testing.T) {
+	os.Clearenv()
+
+	path := writeConfigFile(t, "config.yaml", `
+combined_limits: [ip, token-ip]
+defaults:
+  token_ip:
+    limit: 5
+    block_time: 60
+`)
+	os.Setenv("CONFIG_FILE", path)
+	defer os.Unsetenv("CONFIG_FILE")
+
+	cfg, err := LoadConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{CombinedIP: true, CombinedTokenIP: true}, cfg.CombinedLimits)
+	assert.Equal(t, 5, cfg.RateLimitTokenIP)
+	assert.Equal(t, 60, cfg.RateLimitTokenIPBlockTime)
+
+	os.Setenv("COMBINED_LIMITS", "token-ip, jwt")
+	os.Setenv("RATE_LIMIT_TOKEN_IP", "0")
+	defer func() {
+		os.Unsetenv("COMBINED_LIMITS")
+		os.Unsetenv("RATE_LIMIT_TOKEN_IP")
+	}()
+
+	_, err = LoadConfig()
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.ElementsMatch(t, []string{
+		"RATE_LIMIT_TOKEN_IP must be positive, got 0",
+		`COMBINED_LIMITS: unknown limit "jwt" (expected "ip" or "token-ip")`,
+	}, validationErr.Problems)
+}
+
+func TestLoadConfig_Rejection(t *testing.T) {
+	os.Clearenv()
+
+	cfg, err := LoadConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, 429, cfg.Rejection.Status)
+	assert.Equal(t, FormatJSON, cfg.Rejection.Format)
+	assert.True(t, cfg.Rejection.Negotiate)
+	assert.Equal(t, []string{FormatHTML, FormatJSON, FormatProblem, FormatText}, cfg.Rejection.FormatNames())
+
+	path := writeConfigFile(t, "config.yaml", `
+rejection:
+  status: 503
+  format: text
+  negotiate: false
+  formats:
+    text:
+      body: "slow down, retry in {{.RetryAfter}}s"
+    html:
+      content_type: application/xhtml+xml
+`)
+	os.Setenv("CONFIG_FILE", path)
+	defer os.Unsetenv("CONFIG_FILE")
+
+	cfg, err = LoadConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, 503, cfg.Rejection.Status)
+	assert.Equal(t, FormatText, cfg.Rejection.Format)
+	assert.False(t, cfg.Rejection.Negotiate)
+	assert.Equal(t, "application/xhtml+xml", cfg.Rejection.Formats[FormatHTML].ContentType)
+
+	var body strings.Builder
+	assert.NoError(t, cfg.Rejection.Formats[FormatText].Template.Execute(&body, map[string]int{"RetryAfter": 5}))
+	assert.Equal(t, "slow down, retry in 5s", body.String())
+
+	path = writeConfigFile(t, "config.yaml", `
+rejection:
+  formats:
+    xml:
+      body: "<error/>"
+    json:
+      body: "{{.Limit"
+`)
+	os.Setenv("CONFIG_FILE", path)
+	os.Setenv("REJECTION_STATUS", "200")
+	os.Setenv("REJECTION_FORMAT", "yaml")
+	defer func() {
+		os.Unsetenv("REJECTION_STATUS")
+		os.Unsetenv("REJECTION_FORMAT")
+	}()
+
+	_, err = LoadConfig()
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Len(t, validationErr.Problems, 4)
+	assert.Contains(t, validationErr.Problems, `rejection: unknown format "xml" (expected "json", "text", "html" or "problem")`)
+	assert.Contains(t, validationErr.Problems, "REJECTION_STATUS must be between 400 and 599, got 200")
+	assert.Contains(t, validationErr.Problems, `REJECTION_FORMAT must be one of "json", "text", "html" or "problem", got "yaml"`)
+}
+
+func TestLoadConfig_ShadowMode(t *testing.T) {
+	os.Clearenv()
+
+	cfg, err := LoadConfig()
+	assert.NoError(t, err)
+	assert.False(t, cfg.ShadowMode)
+
+	path := writeConfigFile(t, "config.yaml", `
+shadow: true
+rules:
+  - name: search
+    path: /search
+    limit: 5
+    block_time: 10
+    shadow: true
+`)
+	os.Setenv("CONFIG_FILE", path)
+	defer os.Unsetenv("CONFIG_FILE")
+
+	cfg, err = LoadConfig()
+	assert.NoError(t, err)
+	assert.True(t, cfg.ShadowMode)
+	assert.True(t, cfg.Rules[0].Shadow)
+
+	// The environment can turn the global mode off again
+	os.Setenv("SHADOW_MODE", "false")
+	defer os.Unsetenv("SHADOW_MODE")
+
+	cfg, err = LoadConfig()
+	assert.NoError(t, err)
+	assert.False(t, cfg.ShadowMode)
+
+	os.Setenv("SHADOW_MODE", "maybe")
+
+	_, err = LoadConfig()
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []string{`SHADOW_MODE: "maybe" is not a valid boolean`}, validationErr.Problems)
+}
+
+func TestLoadConfig_Logging(t *testing.T) {
+	os.Clearenv()
+
+	cfg, err := LoadConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, LogFormatText, cfg.LogFormat)
+	assert.Equal(t, "info", cfg.LogLevel)
+	assert.Zero(t, cfg.DecisionLogSample)
+
+	path := writeConfigFile(t, "config.yaml", `
+log:
+  format: json
+  level: debug
+  decision_sample: 0.1
+`)
+	os.Setenv("CONFIG_FILE", path)
+	defer os.Unsetenv("CONFIG_FILE")
+
+	cfg, err = LoadConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, LogFormatJSON, cfg.LogFormat)
+	assert.Equal(t, "debug", cfg.LogLevel)
+	assert.Equal(t, 0.1, cfg.DecisionLogSample)
+
+	os.Setenv("LOG_FORMAT", "xml")
+	os.Setenv("LOG_LEVEL", "loud")
+	os.Setenv("DECISION_LOG_SAMPLE", "2")
+	defer func() {
+		os.Unsetenv("LOG_FORMAT")
+		os.Unsetenv("LOG_LEVEL")
+		os.Unsetenv("DECISION_LOG_SAMPLE")
+	}()
+
+	_, err = LoadConfig()
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.ElementsMatch(t, []string{
+		`LOG_FORMAT must be "text" or "json", got "xml"`,
+		`LOG_LEVEL must be one of "debug", "info", "warn" or "error", got "loud"`,
+		"DECISION_LOG_SAMPLE must be between 0 and 1, got 2",
+	}, validationErr.Problems)
+
+	os.Setenv("DECISION_LOG_SAMPLE", "often")
+	os.Unsetenv("LOG_FORMAT")
+	os.Unsetenv("LOG_LEVEL")
+
+	_, err = LoadConfig()
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []string{`DECISION_LOG_SAMPLE: "often" is not a valid number`}, validationErr.Problems)
+}
+
+func TestLoadConfig_Tracing(t *testing.T) {
+	os.Clearenv()
+
+	cfg, err := LoadConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, TracingNone, cfg.TracingExporter)
+	assert.Equal(t, "http://localhost:4318", cfg.TracingEndpoint)
+	assert.Equal(t, "rate-limiter", cfg.TracingServiceName)
+
+	os.Setenv("TRACING_EXPORTER", "stdout")
+	os.Setenv("OTEL_SERVICE_NAME", "edge")
+	defer func() {
+		os.Unsetenv("TRACING_EXPORTER")
+		os.Unsetenv("OTEL_SERVICE_NAME")
+	}()
+
+	cfg, err = LoadConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, TracingStdout, cfg.TracingExporter)
+	assert.Equal(t, "edge", cfg.TracingServiceName)
+
+	os.Setenv("TRACING_EXPORTER", "jaeger")
+
+	_, err = LoadConfig()
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []string{`TRACING_EXPORTER must be "none", "stdout" or "otlp", got "jaeger"`}, validationErr.Problems)
+
+	os.Setenv("TRACING_EXPORTER", "otlp")
+	os.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://collector:4318")
+	defer os.Unsetenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+
+	cfg, err = LoadConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, TracingOTLP, cfg.TracingExporter)
+	assert.Equal(t, "http://collector:4318", cfg.TracingEndpoint)
+
+	os.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "collector:4318")
+
+	_, err = LoadConfig()
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []string{`OTEL_EXPORTER_OTLP_ENDPOINT must be an http or https URL, got "collector:4318"`}, validationErr.Problems)
+}
+
+func TestLoadConfig_ShutdownTimeout(t *testing.T) {
+	os.Clearenv()
+
+	cfg, err := LoadConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, 15, cfg.ShutdownTimeout)
+	assert.Equal(t, 5, cfg.ShutdownDrainDelay)
+
+	path := writeConfigFile(t, "config.yaml", `
+server:
+  shutdown_timeout: 30
+  drain_delay: 10
+`)
+	os.Setenv("CONFIG_FILE", path)
+	defer os.Unsetenv("CONFIG_FILE")
+
+	cfg, err = LoadConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, 30, cfg.ShutdownTimeout)
+	assert.Equal(t, 10, cfg.ShutdownDrainDelay)
+
+	// The delay can be disabled
+	os.Setenv("SHUTDOWN_DRAIN_DELAY", "0")
+	defer os.Unsetenv("SHUTDOWN_DRAIN_DELAY")
+
+	cfg, err = LoadConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, cfg.ShutdownDrainDelay)
+
+	os.Setenv("SHUTDOWN_TIMEOUT", "0")
+	defer os.Unsetenv("SHUTDOWN_TIMEOUT")
+
+	_, err = LoadConfig()
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []string{"SHUTDOWN_TIMEOUT must be positive, got 0"}, validationErr.Problems)
+
+	os.Setenv("SHUTDOWN_TIMEOUT", "15")
+	os.Setenv("SHUTDOWN_DRAIN_DELAY", "-1")
+
+	_, err = LoadConfig()
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []string{"SHUTDOWN_DRAIN_DELAY must not be negative, got -1"}, validationErr.Problems)
+}
+
+func TestLoadConfig_FileZeroValues(t *testing.T) {
+	os.Clearenv()
+
+	// An explicit 0 in the file is applied, not taken as "not set"
+	path := writeConfigFile(t, "config.yaml", `
+server:
+  drain_delay: 0
+`)
+	os.Setenv("CONFIG_FILE", path)
+	defer os.Unsetenv("CONFIG_FILE")
+
+	cfg, err := LoadConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, cfg.ShutdownDrainDelay)
+
+	// so that invalid zeros are reported instead of falling back to defaults
+	path = writeConfigFile(t, "config.yaml", `
+server:
+  shutdown_timeout: 0
+defaults:
+  ip:
+    limit: 0
+    block_time: 0
+tiers:
+  pro:
+    limit: 10
+    block_time: 60
+tokens:
+  - name: acme
+    value: acme-token
+    tier: pro
+    limit: 0
+`)
+	os.Setenv("CONFIG_FILE", path)
+
+	_, err = LoadConfig()
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.ElementsMatch(t, []string{
+		`token "acme" limit must be positive, got 0`,
+		"RATE_LIMIT_IP must be positive, got 0",
+		"RATE_LIMIT_IP_BLOCK_TIME must be positive, got 0",
+		"SHUTDOWN_TIMEOUT must be positive, got 0",
+	}, validationErr.Problems)
+}
+
+func TestLoadConfig_MetricsPort(t *testing.T) {
+	os.Clearenv()
+
+	cfg, err := LoadConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, "9091", cfg.MetricsPort)
+
+	path := writeConfigFile(t, "config.yaml", `
+metrics:
+  port: "9100"
+`)
+	os.Setenv("CONFIG_FILE", path)
+	defer os.Unsetenv("CONFIG_FILE")
+
+	cfg, err = LoadConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, "9100", cfg.MetricsPort)
+
+	os.Setenv("METRICS_PORT", "9200")
+	defer os.Unsetenv("METRICS_PORT")
+
+	cfg, err = LoadConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, "9200", cfg.MetricsPort)
+}
+
+func TestLoadConfig_Gateway(t *testing.T) {
+	os.Clearenv()
+
+	cfg, err := LoadConfig()
+	assert.NoError(t, err)
+	assert.Nil(t, cfg.Gateway)
+
+	path := writeConfigFile(t, "config.yaml", `
+gateway:
+  upstream: http://app:3000
+  preserve_host: true
+  headers:
+    X-Gateway: rate-limiter
+  routes:
+    - name: users
+      path: /users
+      methods: [GET]
+      upstream: http://users:8080/v1
+      timeout: 5
+      strip_prefix: true
+    - name: slow
+      path: /reports
+      timeout: 120
+`)
+	os.Setenv("CONFIG_FILE", path)
+	os.Setenv("GATEWAY_TIMEOUT", "10")
+	defer func() {
+		os.Unsetenv("CONFIG_FILE")
+		os.Unsetenv("GATEWAY_TIMEOUT")
+	}()
+
+	cfg, err = LoadConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, "http://app:3000", cfg.Gateway.Upstream.String())
+	assert.Equal(t, 10, cfg.Gateway.Timeout)
+	assert.True(t, cfg.Gateway.PreserveHost)
+	assert.Equal(t, map[string]string{"X-Gateway": "rate-limiter"}, cfg.Gateway.Headers)
+
+	route, ok := cfg.Gateway.MatchRoute("GET", "/users/42", cfg.RulePrecedence)
+	assert.True(t, ok)
+	assert.Equal(t, "http://users:8080/v1", route.Target.String())
+	assert.Equal(t, 5, cfg.Gateway.TimeoutFor(route))
+
+	route, ok = cfg.Gateway.MatchRoute("GET", "/reports", cfg.RulePrecedence)
+	assert.True(t, ok)
+	assert.Nil(t, route.Target)
+	assert.Equal(t, 120, cfg.Gateway.TimeoutFor(route))
+
+	_, ok = cfg.Gateway.MatchRoute("POST", "/users/42", cfg.RulePrecedence)
+	assert.False(t, ok)
+	assert.Equal(t, 10, cfg.Gateway.TimeoutFor(Route{}))
+
+	os.Setenv("GATEWAY_UPSTREAM", "app:3000")
+	defer os.Unsetenv("GATEWAY_UPSTREAM")
+
+	_, err = LoadConfig()
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []string{`GATEWAY_UPSTREAM must be an http or https URL, got "app:3000"`}, validationErr.Problems)
+}
+
+func TestLoadConfig_GatewayRouteWithoutUpstream(t *testing.T) {
+	os.Clearenv()
+
+	path := writeConfigFile(t, "config.yaml", `
+gateway:
+  routes:
+    - path: /reports
+      timeout: -1
+`)
+	os.Setenv("CONFIG_FILE", path)
+	defer os.Unsetenv("CONFIG_FILE")
+
+	_, err := LoadConfig()
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []string{
+		"gateway route #1 must have an upstream, since GATEWAY_UPSTREAM is not set",
+		"gateway route #1 timeout must not be negative, got -1",
+	}, validationErr.Problems)
+}
+
+func TestLoadConfig_AccessLists(t *testing.T) {
+	os.Clearenv()
+
+	path := writeConfigFile(t, "config.yaml", `
+access:
+  allow:
+    ips: ["10.0.0.0/8"]
+    tokens: ["partner"]
+  deny:
+    ips: ["203.0.113.7"]
+`)
+	os.Setenv("CONFIG_FILE", path)
+	os.Setenv("DENY_TOKENS", "revoked, leaked")
+	defer func() {
+		os.Unsetenv("CONFIG_FILE")
+		os.Unsetenv("DENY_TOKENS")
+	}()
+
+	cfg, err := LoadConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, cfg.AllowedIPs.Len())
+	assert.Equal(t, 1, cfg.DeniedIPs.Len())
+	assert.True(t, cfg.DeniedIPs.Contains(netip.MustParseAddr("203.0.113.7")))
+	assert.Equal(t, map[string]bool{HashToken("partner"): true}, cfg.AllowedTokens)
+	assert.Equal(t, map[string]bool{HashToken("revoked"): true, HashToken("leaked"): true}, cfg.DeniedTokens)
+
+	os.Setenv("ALLOW_IPS", "not-an-ip")
+	os.Setenv("ALLOW_TOKENS", "revoked")
+	defer func() {
+		os.Unsetenv("ALLOW_IPS")
+		os.Unsetenv("ALLOW_TOKENS")
+	}()
+
+	_, err = LoadConfig()
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.ElementsMatch(t, []string{
+		`ALLOW_IPS: "not-an-ip" is not a valid IP address or CIDR`,
+		"a token is listed in both ALLOW_TOKENS and DENY_TOKENS",
+	}, validationErr.Problems)
+}
+
+func TestLoadConfig_RuleKeys(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+rules:
+  - name: tenant
+    path: /tenants/{tenant}
+    limit: 1
+    block_time: 1
+    key: path:tenant+token
+  - name: orders
+    path: /orders
+    limit: 1
+    block_time: 1
+    key: path:id
+`)
+	os.Setenv("CONFIG_FILE", path)
+	defer os.Unsetenv("CONFIG_FILE")
+
+	_, err := LoadConfig()
+
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []string{
+		`rule "orders" key uses path parameter "id", which is not in its path`,
+	}, validationErr.Problems)
+}
+
+func TestLoadConfig_JWT(t *testing.T) {
+	os.Clearenv()
+
+	cfg, err := LoadConfig()
+	assert.NoError(t, err)
+	assert.Nil(t, cfg.JWTVerifier)
+	assert.Equal(t, "sub", cfg.JWTKeyClaim)
+
+	os.Setenv("JWT_HS256_SECRETS", "s1,s2")
+	os.Setenv("JWT_TIER_CLAIM", "plan")
+	defer func() {
+		os.Unsetenv("JWT_HS256_SECRETS")
+		os.Unsetenv("JWT_TIER_CLAIM")
+	}()
+
+	cfg, err = LoadConfig()
+	assert.NoError(t, err)
+	assert.NotNil(t, cfg.JWTVerifier)
+	assert.Equal(t, "plan", cfg.JWTTierClaim)
+
+	jwks := writeConfigFile(t, "jwks.json", `{"keys": []}`)
+	os.Setenv("JWT_JWKS_FILE", jwks)
+	defer os.Unsetenv("JWT_JWKS_FILE")
+
+	_, err = LoadConfig()
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []string{
+		"JWT_JWKS_FILE " + jwks + ": invalid JWKS: no RSA keys found",
+	}, validationErr.Problems)
+}
+
+func TestGetTierConfig(t *testing.T) {
+	cfg := &Config{
+		RateLimitToken:          100,
+		RateLimitTokenBlockTime: 300,
+		Tiers:                   map[string]TokenConfig{"pro": {Limit: 1000, BlockTime: 60}},
+	}
+
+	tc, ok := cfg.GetTierConfig("pro")
+	assert.True(t, ok)
+	assert.Equal(t, TokenConfig{Limit: 1000, BlockTime: 60, Tier: "pro"}, tc)
+
+	tc, ok = cfg.GetTierConfig("unknown")
+	assert.False(t, ok)
+	assert.Equal(t, TokenConfig{Limit: 100, BlockTime: 300, Tier: DefaultTier}, tc)
+}
+
+func TestLoadConfig_TokenTiers(t *testing.T) {
+	os.Clearenv()
+
+	path := writeConfigFile(t, "config.yaml", `
+tiers:
+  default:
+    limit: 20
+    quota: 1000
+  pro:
+    limit: 500
+    block_time: 30
+    window: 60
+    quota: 100000
+    quota_window: 3600
+`)
+	os.Setenv("CONFIG_FILE", path)
+	os.Setenv("TOKEN_ONE", "one-token")
+	os.Setenv("TOKEN_ONE_TIER", "pro")
+	os.Setenv("TOKEN_TWO", "two-token")
+	os.Setenv("TOKEN_TWO_TIER", "pro")
+	os.Setenv("TOKEN_TWO_LIMIT", "50")
+	os.Setenv("TOKEN_THREE", "three-token")
+	defer os.Clearenv()
+
+	cfg, err := LoadConfig()
+	assert.NoError(t, err)
+
+	// The default tier is the default token limits
+	assert.Equal(t, 20, cfg.RateLimitToken)
+	assert.Equal(t, TokenConfig{Limit: 20, BlockTime: 300, Quota: 1000, Tier: DefaultTier, Name: "THREE"}, cfg.TokenConfigs[HashToken("three-token")])
+
+	assert.Equal(t, TokenConfig{Limit: 500, BlockTime: 30, Window: 60, Quota: 100000, QuotaWindow: 3600, Tier: "pro", Name: "ONE"}, cfg.TokenConfigs[HashToken("one-token")])
+	assert.Equal(t, 50, cfg.TokenConfigs[HashToken("two-token")].Limit)
+	assert.Equal(t, 60, cfg.TokenConfigs[HashToken("two-token")].Window)
+
+	unknown, _ := cfg.GetTokenConfig("unknown-token")
+	assert.Equal(t, TokenConfig{Limit: 20, BlockTime: 300, Quota: 1000, Tier: DefaultTier}, unknown)
+
+	// Environment defaults still win over the default tier
+	os.Setenv("RATE_LIMIT_TOKEN", "25")
+	os.Setenv("TOKEN_ONE_TIER", "gold")
+
+	_, err = LoadConfig()
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []string{`TOKEN_ONE_TIER refers to unknown tier "gold"`}, validationErr.Problems)
+
+	os.Setenv("TOKEN_ONE_TIER", "pro")
+	cfg, err = LoadConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, 25, cfg.TokenConfigs[HashToken("three-token")].Limit)
+}
+
+func TestTokenConfig_Durations(t *testing.T) {
+	tc := TokenConfig{BlockTime: 30}
+	assert.Equal(t, 1*time.Second, tc.WindowDuration())
+	assert.Equal(t, 30*time.Second, tc.BlockDuration())
+	assert.Equal(t, 24*time.Hour, tc.QuotaDuration())
+
+	tc = TokenConfig{Window: 60, QuotaWindow: 3600}
+	assert.Equal(t, 1*time.Minute, tc.WindowDuration())
+	assert.Equal(t, 1*time.Hour, tc.QuotaDuration())
+}
+
+func TestLoadConfig_HashedTokens(t *testing.T) {
+	os.Clearenv()
+
+	tokensFile := writeConfigFile(t, "tokens.yaml", `
+- name: ACME
+  hash: `+HashToken("acme-token")+`
+  tier: pro
+- name: PLAIN
+  value: plain-token
+`)
+	path := writeConfigFile(t, "config.yaml", `
+tiers:
+  pro:
+    limit: 500
+    block_time: 30
+tokens_file: `+tokensFile+`
+`)
+	os.Setenv("CONFIG_FILE", path)
+	os.Setenv("TOKEN_ENV", "sha256:"+HashToken("env-token"))
+	defer os.Clearenv()
+
+	cfg, err := LoadConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, tokensFile, cfg.TokensFile)
+
+	// Only hashes are kept
+	assert.Len(t, cfg.TokenConfigs, 3)
+	for hash := range cfg.TokenConfigs {
+		assert.True(t, IsTokenHash(hash))
+	}
+
+	tc, ok := cfg.GetTokenConfig("acme-token")
+	assert.True(t, ok)
+	assert.Equal(t, TokenConfig{Limit: 500, BlockTime: 30, Tier: "pro", Name: "ACME"}, tc)
+
+	_, ok = cfg.GetTokenConfig("plain-token")
+	assert.True(t, ok)
+	_, ok = cfg.GetTokenConfig("env-token")
+	assert.True(t, ok)
+
+	// Invalid hashes and entries with both a value and a hash are rejected
+	os.WriteFile(tokensFile, []byte(`
+- name: BAD
+  hash: not-a-hash
+- name: BOTH
+  value: x
+  hash: `+HashToken("x")+`
+`), 0o644)
+	os.Setenv("TOKEN_ENV", "sha256:1234")
+
+	_, err = LoadConfig()
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.ElementsMatch(t, []string{
+		`TOKENS_FILE token "BAD" hash is not a valid SHA-256 hash`,
+		`TOKENS_FILE token "BOTH" must have either a value or a hash, not both`,
+		"TOKEN_ENV is not a valid SHA-256 hash",
+	}, validationErr.Problems)
+}
+
+func TestHashToken(t *testing.T) {
+	hash := HashToken("abc123")
+	assert.Equal(t, "6ca13d52ca70c883e0f0bb101e425a89e8624de51db2d2392593af6a84118090", hash)
+	assert.True(t, IsTokenHash(hash))
+	assert.False(t, IsTokenHash("abc123"))
+	assert.False(t, IsTokenHash(strings.ToUpper(hash)))
+	assert.Equal(t, "6ca13d52ca70", ShortHash(hash))
 }
@@ -0,0 +1,257 @@
+package configs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileConfig mirrors the structure of the optional configuration file
+// (YAML or JSON) pointed to by CONFIG_FILE. Numbers are pointers, so that
+// an explicit 0 is applied (and validated) rather than taken as "not set";
+// empty strings and lists mean "not set".
+type fileConfig struct {
+	Server   fileServer          `yaml:"server" json:"server"`
+	Admin    fileAdmin           `yaml:"admin" json:"admin"`
+	Metrics  fileMetrics         `yaml:"metrics" json:"metrics"`
+	Storage  fileStorage         `yaml:"storage" json:"storage"`
+	Defaults fileDefaults        `yaml:"defaults" json:"defaults"`
+	Tiers    map[string]fileTier `yaml:"tiers" json:"tiers"`
+	Tokens   []fileToken         `yaml:"tokens" json:"tokens"`
+	Rules    []Rule              `yaml:"rules" json:"rules"`
+
+	TokensFile     string   `yaml:"tokens_file" json:"tokens_file"`
+	RulePrecedence string   `yaml:"rule_precedence" json:"rule_precedence"`
+	Headers        string   `yaml:"headers" json:"headers"`
+	UnknownTokens  string   `yaml:"unknown_tokens" json:"unknown_tokens"`
+	CombinedLimits []string `yaml:"combined_limits" json:"combined_limits"`
+	Shadow         bool     `yaml:"shadow" json:"shadow"`
+
+	ClientIP  fileClientIP  `yaml:"client_ip" json:"client_ip"`
+	Access    fileAccess    `yaml:"access" json:"access"`
+	JWT       fileJWT       `yaml:"jwt" json:"jwt"`
+	Rejection fileRejection `yaml:"rejection" json:"rejection"`
+	Log       fileLog       `yaml:"log" json:"log"`
+	Tracing   fileTracing   `yaml:"tracing" json:"tracing"`
+	Gateway   fileGateway   `yaml:"gateway" json:"gateway"`
+}
+
+type fileGateway struct {
+	Upstream     string            `yaml:"upstream" json:"upstream"`
+	Timeout      *int              `yaml:"timeout" json:"timeout"`
+	PreserveHost bool              `yaml:"preserve_host" json:"preserve_host"`
+	Headers      map[string]string `yaml:"headers" json:"headers"`
+	Routes       []Route           `yaml:"routes" json:"routes"`
+}
+
+type fileTracing struct {
+	Exporter    string `yaml:"exporter" json:"exporter"`
+	Endpoint    string `yaml:"endpoint" json:"endpoint"`
+	ServiceName string `yaml:"service_name" json:"service_name"`
+}
+
+type fileLog struct {
+	Format         string   `yaml:"format" json:"format"`
+	Level          string   `yaml:"level" json:"level"`
+	DecisionSample *float64 `yaml:"decision_sample" json:"decision_sample"`
+}
+
+type fileRejection struct {
+	Status    *int                          `yaml:"status" json:"status"`
+	Format    string                        `yaml:"format" json:"format"`
+	Negotiate *bool                         `yaml:"negotiate" json:"negotiate"`
+	Formats   map[string]fileResponseFormat `yaml:"formats" json:"formats"`
+}
+
+type fileResponseFormat struct {
+	ContentType string `yaml:"content_type" json:"content_type"`
+	Body        string `yaml:"body" json:"body"`
+}
+
+type fileJWT struct {
+	HS256Secrets []string `yaml:"hs256_secrets" json:"hs256_secrets"`
+	JWKSFile     string   `yaml:"jwks_file" json:"jwks_file"`
+	KeyClaim     string   `yaml:"key_claim" json:"key_claim"`
+	TierClaim    string   `yaml:"tier_claim" json:"tier_claim"`
+}
+
+type fileAccess struct {
+	Allow fileAccessList `yaml:"allow" json:"allow"`
+	Deny  fileAccessList `yaml:"deny" json:"deny"`
+}
+
+type fileAccessList struct {
+	IPs    []string `yaml:"ips" json:"ips"`
+	Tokens []string `yaml:"tokens" json:"tokens"`
+}
+
+type fileClientIP struct {
+	Source         string   `yaml:"source" json:"source"`
+	TrustedProxies []string `yaml:"trusted_proxies" json:"trusted_proxies"`
+	IPv4Prefix     *int     `yaml:"ipv4_prefix" json:"ipv4_prefix"`
+	IPv6Prefix     *int     `yaml:"ipv6_prefix" json:"ipv6_prefix"`
+}
+
+type fileServer struct {
+	Port            string `yaml:"port" json:"port"`
+	ShutdownTimeout *int   `yaml:"shutdown_timeout" json:"shutdown_timeout"`
+	DrainDelay      *int   `yaml:"drain_delay" json:"drain_delay"`
+}
+
+type fileAdmin struct {
+	Port  string `yaml:"port" json:"port"`
+	Token string `yaml:"token" json:"token"`
+}
+
+type fileMetrics struct {
+	Port string `yaml:"port" json:"port"`
+}
+
+type fileStorage struct {
+	Redis fileRedis `yaml:"redis" json:"redis"`
+}
+
+type fileRedis struct {
+	Host     string `yaml:"host" json:"host"`
+	Port     string `yaml:"port" json:"port"`
+	Password string `yaml:"password" json:"password"`
+	DB       *int   `yaml:"db" json:"db"`
+}
+
+type fileDefaults struct {
+	IP      fileLimit `yaml:"ip" json:"ip"`
+	Token   fileLimit `yaml:"token" json:"token"`
+	TokenIP fileLimit `yaml:"token_ip" json:"token_ip"`
+}
+
+type fileLimit struct {
+	Limit     *int `yaml:"limit" json:"limit"`
+	BlockTime *int `yaml:"block_time" json:"block_time"`
+}
+
+type fileTier struct {
+	Limit       *int `yaml:"limit" json:"limit"`
+	BlockTime   *int `yaml:"block_time" json:"block_time"`
+	Window      int  `yaml:"window" json:"window"`
+	Quota       int  `yaml:"quota" json:"quota"`
+	QuotaWindow int  `yaml:"quota_window" json:"quota_window"`
+}
+
+type fileToken struct {
+	Name      string `yaml:"name" json:"name"`
+	Value     string `yaml:"value" json:"value"`
+	Hash      string `yaml:"hash" json:"hash"`
+	Tier      string `yaml:"tier" json:"tier"`
+	Limit     *int   `yaml:"limit" json:"limit"`
+	BlockTime *int   `yaml:"block_time" json:"block_time"`
+}
+
+// loadFile reads a configuration file. The format is chosen by extension:
+// .json is parsed as JSON, anything else as YAML.
+func loadFile(path string) (*fileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	fc := &fileConfig{}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(fc)
+	default:
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(fc)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return fc, nil
+}
+
+// apply copies every value set in the file over cfg
+func (fc *fileConfig) apply(cfg *Config) {
+	setString(&cfg.ServerPort, fc.Server.Port)
+	setInt(&cfg.ShutdownTimeout, fc.Server.ShutdownTimeout)
+	setInt(&cfg.ShutdownDrainDelay, fc.Server.DrainDelay)
+	setString(&cfg.AdminPort, fc.Admin.Port)
+	setString(&cfg.AdminToken, fc.Admin.Token)
+	setString(&cfg.MetricsPort, fc.Metrics.Port)
+
+	setString(&cfg.RedisHost, fc.Storage.Redis.Host)
+	setString(&cfg.RedisPort, fc.Storage.Redis.Port)
+	setString(&cfg.RedisPassword, fc.Storage.Redis.Password)
+	setInt(&cfg.RedisDB, fc.Storage.Redis.DB)
+
+	setString(&cfg.TokensFile, fc.TokensFile)
+
+	setInt(&cfg.RateLimitIP, fc.Defaults.IP.Limit)
+	setInt(&cfg.RateLimitIPBlockTime, fc.Defaults.IP.BlockTime)
+	setInt(&cfg.RateLimitToken, fc.Defaults.Token.Limit)
+	setInt(&cfg.RateLimitTokenBlockTime, fc.Defaults.Token.BlockTime)
+	setInt(&cfg.RateLimitTokenIP, fc.Defaults.TokenIP.Limit)
+	setInt(&cfg.RateLimitTokenIPBlockTime, fc.Defaults.TokenIP.BlockTime)
+
+	for name, tier := range fc.Tiers {
+		tc := TokenConfig{
+			Window:      tier.Window,
+			Quota:       tier.Quota,
+			QuotaWindow: tier.QuotaWindow,
+		}
+		setInt(&tc.Limit, tier.Limit)
+		setInt(&tc.BlockTime, tier.BlockTime)
+		cfg.Tiers[name] = tc
+	}
+
+	// The default tier is another way of setting the default token limits
+	if tier, ok := fc.Tiers[DefaultTier]; ok {
+		setInt(&cfg.RateLimitToken, tier.Limit)
+		setInt(&cfg.RateLimitTokenBlockTime, tier.BlockTime)
+	}
+
+	cfg.Rules = append(cfg.Rules, fc.Rules...)
+	setString(&cfg.RulePrecedence, fc.RulePrecedence)
+	setString(&cfg.RateLimitHeaders, fc.Headers)
+	setString(&cfg.UnknownTokenPolicy, fc.UnknownTokens)
+	if fc.Shadow {
+		cfg.ShadowMode = true
+	}
+	setString(&cfg.ClientIPSource, fc.ClientIP.Source)
+	setInt(&cfg.IPv4Prefix, fc.ClientIP.IPv4Prefix)
+	setInt(&cfg.IPv6Prefix, fc.ClientIP.IPv6Prefix)
+	setString(&cfg.JWTKeyClaim, fc.JWT.KeyClaim)
+	setString(&cfg.JWTTierClaim, fc.JWT.TierClaim)
+	setString(&cfg.LogFormat, fc.Log.Format)
+	setString(&cfg.LogLevel, fc.Log.Level)
+	setString(&cfg.TracingExporter, fc.Tracing.Exporter)
+	setString(&cfg.TracingEndpoint, fc.Tracing.Endpoint)
+	setString(&cfg.TracingServiceName, fc.Tracing.ServiceName)
+	setFloat(&cfg.DecisionLogSample, fc.Log.DecisionSample)
+}
+
+func setString(dst *string, value string) {
+	if value != "" {
+		*dst = value
+	}
+}
+
+func setInt(dst *int, value *int) {
+	if value != nil {
+		*dst = *value
+	}
+}
+
+func setFloat(dst *float64, value *float64) {
+	if value != nil {
+		*dst = *value
+	}
+}
@@ -0,0 +1,128 @@
+package configs
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// Gateway describes the reverse proxy mode: requests that pass the rate
+// limiter are forwarded to Upstream, or to the upstream of the first route
+// that matches them (see RULE_PRECEDENCE).
+type Gateway struct {
+	Upstream *url.URL
+	Timeout  int
+
+	// PreserveHost sends the Host header of the client instead of the one
+	// of the upstream; Headers are set on every proxied request
+	PreserveHost bool
+	Headers      map[string]string
+
+	Routes []Route
+}
+
+// Route sends the requests matching Path and Methods, which work as in
+// rules, to its own upstream
+type Route struct {
+	Name     string   `yaml:"name" json:"name"`
+	Path     string   `yaml:"path" json:"path"`
+	Methods  []string `yaml:"methods" json:"methods"`
+	Upstream string   `yaml:"upstream" json:"upstream"`
+
+	// Timeout in seconds; 0 uses the timeout of the gateway
+	Timeout int `yaml:"timeout" json:"timeout"`
+
+	// StripPrefix removes the segments matched by Path before proxying,
+	// e.g. "/users/42" is sent as "/42" by a "/users" route
+	StripPrefix bool `yaml:"strip_prefix" json:"strip_prefix"`
+
+	// Target is the parsed Upstream, nil when the route uses the upstream
+	// of the gateway
+	Target *url.URL `yaml:"-" json:"-"`
+}
+
+// MatchRoute returns the route that applies to a request, if any
+func (g *Gateway) MatchRoute(method, path, precedence string) (Route, bool) {
+	best := -1
+	bestScore := -1
+
+	for i, route := range g.Routes {
+		score, ok := Rule{Path: route.Path, Methods: route.Methods}.match(method, path)
+		if !ok {
+			continue
+		}
+
+		if precedence != PrecedenceMostSpecific {
+			return route, true
+		}
+		if score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+
+	if best < 0 {
+		return Route{}, false
+	}
+	return g.Routes[best], true
+}
+
+// TimeoutFor returns how long a request sent through route may take
+func (g *Gateway) TimeoutFor(route Route) int {
+	if route.Timeout > 0 {
+		return route.Timeout
+	}
+	return g.Timeout
+}
+
+// loadGateway builds the gateway from GATEWAY_UPSTREAM, GATEWAY_TIMEOUT and
+// the gateway section of the file. Gateway mode stays disabled (nil) when
+// neither an upstream nor routes are configured.
+func (c *Config) loadGateway(l *loader, fc *fileConfig) {
+	var section fileGateway
+	if fc != nil {
+		section = fc.Gateway
+	}
+
+	upstream := getEnv("GATEWAY_UPSTREAM", section.Upstream)
+	if upstream == "" && len(section.Routes) == 0 {
+		return
+	}
+
+	c.Gateway = &Gateway{
+		Timeout:      30,
+		PreserveHost: section.PreserveHost,
+		Headers:      section.Headers,
+		Routes:       append([]Route{}, section.Routes...),
+	}
+	setInt(&c.Gateway.Timeout, section.Timeout)
+	c.Gateway.Timeout = l.envInt("GATEWAY_TIMEOUT", c.Gateway.Timeout)
+
+	if upstream != "" {
+		c.Gateway.Upstream = l.upstreamURL("GATEWAY_UPSTREAM", upstream)
+	}
+	for i := range c.Gateway.Routes {
+		route := &c.Gateway.Routes[i]
+		switch {
+		case route.Upstream != "":
+			route.Target = l.upstreamURL(routeLabel(i, *route)+" upstream", route.Upstream)
+		case upstream == "":
+			l.addf("%s must have an upstream, since GATEWAY_UPSTREAM is not set", routeLabel(i, *route))
+		}
+	}
+}
+
+// upstreamURL parses an upstream, which must be an absolute http(s) URL
+func (l *loader) upstreamURL(key, value string) *url.URL {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		l.addf("%s must be an http or https URL, got %q", key, value)
+		return nil
+	}
+	return u
+}
+
+func routeLabel(i int, route Route) string {
+	if route.Name == "" {
+		return fmt.Sprintf("gateway route #%d", i+1)
+	}
+	return fmt.Sprintf("gateway route %q", route.Name)
+}
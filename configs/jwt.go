@@ -0,0 +1,42 @@
+package configs
+
+import (
+	"crypto/rsa"
+	"os"
+
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/jwt"
+)
+
+// loadJWT builds the JWT verifier from JWT_HS256_SECRETS and JWT_JWKS_FILE
+// (or the jwt section of the file). The JWKS file is read again on every
+// reload, so rotated keys are picked up without a restart.
+func (c *Config) loadJWT(l *loader, fc *fileConfig) {
+	var section fileJWT
+	if fc != nil {
+		section = fc.JWT
+	}
+
+	var secrets [][]byte
+	for _, secret := range envList("JWT_HS256_SECRETS", section.HS256Secrets) {
+		secrets = append(secrets, []byte(secret))
+	}
+
+	var keys map[string]*rsa.PublicKey
+	if path := getEnv("JWT_JWKS_FILE", section.JWKSFile); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			l.addf("JWT_JWKS_FILE: %v", err)
+		} else if keys, err = jwt.ParseJWKS(data); err != nil {
+			l.addf("JWT_JWKS_FILE %s: %v", path, err)
+		}
+	}
+
+	if len(secrets) == 0 && len(keys) == 0 {
+		return
+	}
+	c.JWTVerifier = jwt.NewVerifier(secrets, keys)
+
+	if c.JWTKeyClaim == "" {
+		l.addf("JWT_KEY_CLAIM must not be empty when JWT verification is enabled")
+	}
+}
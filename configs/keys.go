@@ -0,0 +1,87 @@
+package configs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Key parts a rule can be keyed on. A rule key is either KeyAuto, KeyGlobal
+// or one or more parts joined with "+", e.g. "token+route" or
+// "ip+header:User-Agent". Parts marked with <name> take an argument.
+const (
+	// KeyPartIP is the client IP, aggregated to the configured prefix
+	KeyPartIP = KeyIP
+
+	// KeyPartToken is the API_KEY header
+	KeyPartToken = "token"
+
+	// KeyPartJWT is the identity claim of a valid bearer JWT
+	KeyPartJWT = "jwt"
+
+	// KeyPartRoute is the request method and path
+	KeyPartRoute = "route"
+
+	// KeyPartHeader is a request header: "header:<name>"
+	KeyPartHeader = "header"
+
+	// KeyPartQuery is a query string parameter: "query:<name>"
+	KeyPartQuery = "query"
+
+	// KeyPartCookie is a cookie: "cookie:<name>"
+	KeyPartCookie = "cookie"
+
+	// KeyPartBasicAuth is the username of the Basic Authorization header
+	KeyPartBasicAuth = "basic-auth"
+
+	// KeyPartPath is a parameter of the rule path: "path:<name>" for a
+	// rule path containing "{name}"
+	KeyPartPath = "path"
+)
+
+// KeyPart is one component of a rule key
+type KeyPart struct {
+	Kind string
+	Arg  string
+}
+
+func (p KeyPart) String() string {
+	if p.Arg == "" {
+		return p.Kind
+	}
+	return p.Kind + ":" + p.Arg
+}
+
+// ParseKey splits a rule key into its parts. KeyAuto and KeyGlobal are
+// returned as a single part and cannot be combined with others.
+func ParseKey(key string) ([]KeyPart, error) {
+	if key == "" {
+		key = KeyAuto
+	}
+
+	var parts []KeyPart
+	for _, raw := range strings.Split(key, "+") {
+		kind, arg, _ := strings.Cut(strings.TrimSpace(raw), ":")
+		part := KeyPart{Kind: kind, Arg: arg}
+
+		switch kind {
+		case KeyAuto, KeyGlobal:
+			if strings.Contains(key, "+") {
+				return nil, fmt.Errorf("%q cannot be combined with other key parts", kind)
+			}
+		case KeyPartIP, KeyPartToken, KeyPartJWT, KeyPartRoute, KeyPartBasicAuth:
+			if arg != "" {
+				return nil, fmt.Errorf("key part %q takes no argument", kind)
+			}
+		case KeyPartHeader, KeyPartQuery, KeyPartCookie, KeyPartPath:
+			if arg == "" {
+				return nil, fmt.Errorf("key part %q needs a name, e.g. %q", kind, kind+":name")
+			}
+		default:
+			return nil, fmt.Errorf("unknown key part %q", kind)
+		}
+
+		parts = append(parts, part)
+	}
+
+	return parts, nil
+}
@@ -0,0 +1,34 @@
+package configs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseKey(t *testing.T) {
+	parts, err := ParseKey("")
+	assert.NoError(t, err)
+	assert.Equal(t, []KeyPart{{Kind: KeyAuto}}, parts)
+
+	parts, err = ParseKey("token+route")
+	assert.NoError(t, err)
+	assert.Equal(t, []KeyPart{{Kind: KeyPartToken}, {Kind: KeyPartRoute}}, parts)
+
+	parts, err = ParseKey("ip + header:User-Agent")
+	assert.NoError(t, err)
+	assert.Equal(t, []KeyPart{{Kind: KeyPartIP}, {Kind: KeyPartHeader, Arg: "User-Agent"}}, parts)
+	assert.Equal(t, "header:User-Agent", parts[1].String())
+
+	invalid := map[string]string{
+		"device":          `unknown key part "device"`,
+		"cookie":          `key part "cookie" needs a name, e.g. "cookie:name"`,
+		"ip:v6":           `key part "ip" takes no argument`,
+		"global+token":    `"global" cannot be combined with other key parts`,
+		"query:page+auto": `"auto" cannot be combined with other key parts`,
+	}
+	for key, message := range invalid {
+		_, err := ParseKey(key)
+		assert.EqualError(t, err, message, key)
+	}
+}
@@ -0,0 +1,130 @@
+package configs
+
+import (
+	"net/netip"
+	"strings"
+)
+
+// Client IP sources
+const (
+	// ClientIPAuto uses the Forwarded header when present, then
+	// X-Forwarded-For, then X-Real-IP, trusting them only through
+	// TrustedProxies
+	ClientIPAuto = "auto"
+
+	// ClientIPForwarded only uses the RFC 7239 Forwarded header
+	ClientIPForwarded = "forwarded"
+
+	// ClientIPXForwardedFor only uses the X-Forwarded-For header
+	ClientIPXForwardedFor = "x-forwarded-for"
+
+	// ClientIPXRealIP only uses the X-Real-IP header
+	ClientIPXRealIP = "x-real-ip"
+
+	// ClientIPRemoteAddr ignores every header and uses the connection address
+	ClientIPRemoteAddr = "remote-addr"
+)
+
+// IsTrustedProxy reports whether addr belongs to one of the trusted proxies
+func (c *Config) IsTrustedProxy(addr netip.Addr) bool {
+	return containsAddr(c.TrustedProxies, addr)
+}
+
+// AggregateIP returns the subnet an address is limited as: the address is
+// masked to IPv4Prefix or IPv6Prefix bits, so that every address in the
+// subnet shares one counter. IPv4-mapped IPv6 addresses count as IPv4.
+// Full-length (or unset) prefixes keep the plain address.
+func (c *Config) AggregateIP(addr netip.Addr) string {
+	addr = addr.Unmap().WithZone("")
+
+	bits := c.IPv4Prefix
+	if addr.Is6() {
+		bits = c.IPv6Prefix
+	}
+
+	if bits <= 0 || bits >= addr.BitLen() {
+		return addr.String()
+	}
+
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return addr.String()
+	}
+	return prefix.String()
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// envPrefixes reads a comma-separated list of IPs and CIDRs from the
+// environment, falling back to the given list. Plain IPs are turned into
+// single-address prefixes and invalid entries are recorded as problems.
+func (l *loader) envPrefixes(key string, fallback []string) []netip.Prefix {
+	values := envList(key, fallback)
+
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		prefix, err := parsePrefix(value)
+		if err != nil {
+			l.addf("%s: %q is not a valid IP address or CIDR", key, value)
+			continue
+		}
+		prefixes = append(prefixes, prefix)
+	}
+
+	return prefixes
+}
+
+func parsePrefix(value string) (netip.Prefix, error) {
+	if strings.Contains(value, "/") {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return prefix.Masked(), nil
+	}
+
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// envList reads a comma-separated list of values from the environment,
+// falling back to the given list
+func envList(key string, fallback []string) []string {
+	if raw := getEnv(key, ""); raw != "" {
+		return splitList(raw)
+	}
+	return fallback
+}
+
+// envSet is envList as a set
+func envSet(key string, fallback []string) map[string]bool {
+	values := envList(key, fallback)
+
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
+
+func splitList(raw string) []string {
+	var values []string
+	for _, value := range strings.Split(raw, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,35 @@
+package configs
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregateIP(t *testing.T) {
+	cfg := &Config{IPv4Prefix: 32, IPv6Prefix: 64}
+
+	tests := []struct {
+		addr, expected string
+	}{
+		{"192.168.1.10", "192.168.1.10"},
+		{"2001:db8:1:2:3:4:5:6", "2001:db8:1:2::/64"},
+		{"2001:db8:1:2::ffff", "2001:db8:1:2::/64"},
+		{"::ffff:192.168.1.10", "192.168.1.10"},
+		{"fe80::1%eth0", "fe80::/64"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, cfg.AggregateIP(netip.MustParseAddr(tt.addr)), tt.addr)
+	}
+
+	cfg = &Config{IPv4Prefix: 24, IPv6Prefix: 128}
+	assert.Equal(t, "192.168.1.0/24", cfg.AggregateIP(netip.MustParseAddr("192.168.1.10")))
+	assert.Equal(t, "192.168.1.0/24", cfg.AggregateIP(netip.MustParseAddr("::ffff:192.168.1.10")))
+	assert.Equal(t, "2001:db8::1", cfg.AggregateIP(netip.MustParseAddr("2001:db8::1")))
+
+	// Unset prefixes keep plain addresses
+	cfg = &Config{}
+	assert.Equal(t, "2001:db8::1", cfg.AggregateIP(netip.MustParseAddr("2001:db8::1")))
+}
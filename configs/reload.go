@@ -0,0 +1,137 @@
+package configs
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Provider gives access to the configuration currently in effect.
+// Consumers should call Current once per request and use the returned
+// value for the whole request, so that a reload never mixes two configs.
+type Provider interface {
+	Current() *Config
+}
+
+// Current makes a static *Config usable as a Provider
+func (c *Config) Current() *Config {
+	return c
+}
+
+// Reloader holds the active configuration and swaps it atomically when a
+// reload succeeds. A failed reload leaves the previous configuration active.
+type Reloader struct {
+	current atomic.Pointer[Config]
+	load    func() (*Config, error)
+	mu      sync.Mutex
+
+	// Incremented by every successful reload, starting at 1
+	version atomic.Uint64
+
+	// Modification time and size of the configuration file when it was
+	// last loaded, used by Watch to detect changes
+	fileMod  time.Time
+	fileSize int64
+}
+
+func NewReloader(cfg *Config) *Reloader {
+	r := &Reloader{load: LoadConfig}
+	r.current.Store(cfg)
+	r.version.Store(1)
+	r.fileMod, r.fileSize = fileStamp(cfg.ConfigFile)
+	return r
+}
+
+func (r *Reloader) Current() *Config {
+	return r.current.Load()
+}
+
+// Version identifies the active configuration: 1 for the one the reloader
+// was created with, incremented by every successful reload
+func (r *Reloader) Version() uint64 {
+	return r.version.Load()
+}
+
+// Reload loads the configuration again and makes it active. When loading
+// fails the error is returned and the current configuration is kept.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.reload()
+}
+
+func (r *Reloader) reload() error {
+	r.fileMod, r.fileSize = fileStamp(r.Current().ConfigFile)
+
+	cfg, err := r.load()
+	if err != nil {
+		return err
+	}
+
+	old := r.current.Swap(cfg)
+	r.version.Add(1)
+	if old.ServerPort != cfg.ServerPort || old.AdminPort != cfg.AdminPort || old.MetricsPort != cfg.MetricsPort ||
+		old.ShutdownTimeout != cfg.ShutdownTimeout || old.ShutdownDrainDelay != cfg.ShutdownDrainDelay ||
+		old.RedisHost != cfg.RedisHost || old.RedisPort != cfg.RedisPort || old.RedisDB != cfg.RedisDB ||
+		old.LogFormat != cfg.LogFormat || old.LogLevel != cfg.LogLevel ||
+		old.TracingExporter != cfg.TracingExporter || old.TracingServiceName != cfg.TracingServiceName ||
+		old.TracingEndpoint != cfg.TracingEndpoint ||
+		(old.Gateway == nil) != (cfg.Gateway == nil) {
+		slog.Warn("Configuration reloaded: server ports, storage, log, tracing and enabling gateway mode only take effect after a restart")
+	}
+
+	return nil
+}
+
+// Watch polls the configuration file every interval and reloads it when
+// its modification time or size changes. It returns when ctx is done.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	path := r.Current().ConfigFile
+	if path == "" || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := r.reloadIfChanged(path)
+			if !changed {
+				continue
+			}
+			if err != nil {
+				slog.Error("Configuration reload rejected, keeping previous configuration", "error", err)
+				continue
+			}
+			slog.Info("Configuration reloaded", "file", path)
+		}
+	}
+}
+
+func (r *Reloader) reloadIfChanged(path string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	mod, size := fileStamp(path)
+	if mod.Equal(r.fileMod) && size == r.fileSize {
+		return false, nil
+	}
+
+	return true, r.reload()
+}
+
+func fileStamp(path string) (time.Time, int64) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, -1
+	}
+	return info.ModTime(), info.Size()
+}
@@ -0,0 +1,75 @@
+package configs
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReloader_Reload(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "defaults:\n  ip:\n    limit: 15\n")
+	os.Setenv("CONFIG_FILE", path)
+	defer os.Unsetenv("CONFIG_FILE")
+
+	cfg, err := LoadConfig()
+	assert.NoError(t, err)
+
+	reloader := NewReloader(cfg)
+	assert.Equal(t, 15, reloader.Current().RateLimitIP)
+	assert.Equal(t, uint64(1), reloader.Version())
+
+	err = os.WriteFile(path, []byte("defaults:\n  ip:\n    limit: 25\n"), 0o600)
+	assert.NoError(t, err)
+
+	err = reloader.Reload()
+	assert.NoError(t, err)
+	assert.Equal(t, 25, reloader.Current().RateLimitIP)
+	assert.Equal(t, uint64(2), reloader.Version())
+
+	// The previously returned config is not mutated by the reload
+	assert.Equal(t, 15, cfg.RateLimitIP)
+}
+
+func TestReloader_InvalidConfigKeepsPrevious(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "defaults:\n  ip:\n    limit: 15\n")
+	os.Setenv("CONFIG_FILE", path)
+	defer os.Unsetenv("CONFIG_FILE")
+
+	cfg, err := LoadConfig()
+	assert.NoError(t, err)
+
+	reloader := NewReloader(cfg)
+
+	err = os.WriteFile(path, []byte("defaults: [not, a, map]\n"), 0o600)
+	assert.NoError(t, err)
+
+	err = reloader.Reload()
+	assert.Error(t, err)
+	assert.Same(t, cfg, reloader.Current())
+	assert.Equal(t, uint64(1), reloader.Version())
+}
+
+func TestReloader_WatchFileChange(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "defaults:\n  ip:\n    limit: 15\n")
+	os.Setenv("CONFIG_FILE", path)
+	defer os.Unsetenv("CONFIG_FILE")
+
+	cfg, err := LoadConfig()
+	assert.NoError(t, err)
+
+	reloader := NewReloader(cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reloader.Watch(ctx, 10*time.Millisecond)
+
+	err = os.WriteFile(path, []byte("defaults:\n  ip:\n    limit: 250\n"), 0o600)
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		return reloader.Current().RateLimitIP == 250
+	}, 1*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,150 @@
+package configs
+
+import (
+	"encoding/json"
+	htmltemplate "html/template"
+	"io"
+	"net/http"
+	"sort"
+	texttemplate "text/template"
+)
+
+// Rejection response formats
+const (
+	FormatJSON    = "json"
+	FormatText    = "text"
+	FormatHTML    = "html"
+	FormatProblem = "problem"
+)
+
+// Template is a parsed response body template
+type Template interface {
+	Execute(w io.Writer, data any) error
+}
+
+// ResponseFormat is the content type and body template of one format
+type ResponseFormat struct {
+	ContentType string
+	Body        string
+	Template    Template
+}
+
+// RejectionResponse describes the response sent to rate limited requests.
+// Format is sent unless Negotiate is set and the Accept header of the
+// request prefers one of the other Formats.
+type RejectionResponse struct {
+	Status    int
+	Format    string
+	Negotiate bool
+	Formats   map[string]ResponseFormat
+}
+
+// defaultFormats are the built-in formats; templates of the file replace
+// their bodies and content types
+var defaultFormats = map[string]ResponseFormat{
+	FormatJSON: {
+		ContentType: "application/json",
+		Body:        `{"error": "you have reached the maximum number of requests or actions allowed within a certain time frame"}` + "\n",
+	},
+	FormatText: {
+		ContentType: "text/plain; charset=utf-8",
+		Body:        "you have reached the maximum number of requests or actions allowed within a certain time frame, retry in {{.RetryAfter}} seconds\n",
+	},
+	FormatHTML: {
+		ContentType: "text/html; charset=utf-8",
+		Body: `<!DOCTYPE html>
+<html><head><title>Too Many Requests</title></head>
+<body><h1>Too Many Requests</h1><p>You have reached the maximum of {{.Limit}} requests allowed. Retry in {{.RetryAfter}} seconds.</p></body></html>
+`,
+	},
+	FormatProblem: {
+		ContentType: "application/problem+json",
+		Body:        `{"type": "about:blank", "title": "Too Many Requests", "status": {{.Status}}, "detail": "you have reached the maximum of {{.Limit}} requests allowed", "retry_after": {{.RetryAfter}}}` + "\n",
+	},
+}
+
+// templateFuncs are available to every text template; json encodes a value
+// so that it can be embedded safely in JSON bodies
+var templateFuncs = texttemplate.FuncMap{
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// DefaultRejection returns the built-in rejection response
+func DefaultRejection() *RejectionResponse {
+	rejection := &RejectionResponse{
+		Status:    http.StatusTooManyRequests,
+		Format:    FormatJSON,
+		Negotiate: true,
+		Formats:   make(map[string]ResponseFormat, len(defaultFormats)),
+	}
+	for name, format := range defaultFormats {
+		rejection.Formats[name] = format
+	}
+	// The built-in templates always parse
+	rejection.parse(&loader{})
+	return rejection
+}
+
+// FormatNames returns the names of the formats in a stable order
+func (rr *RejectionResponse) FormatNames() []string {
+	names := make([]string, 0, len(rr.Formats))
+	for name := range rr.Formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// loadRejection builds the rejection response from REJECTION_STATUS,
+// REJECTION_FORMAT and the rejection section of the file
+func (c *Config) loadRejection(l *loader, fc *fileConfig) {
+	c.Rejection = DefaultRejection()
+
+	if fc != nil {
+		section := fc.Rejection
+		setInt(&c.Rejection.Status, section.Status)
+		setString(&c.Rejection.Format, section.Format)
+		if section.Negotiate != nil {
+			c.Rejection.Negotiate = *section.Negotiate
+		}
+
+		for name, format := range section.Formats {
+			defaults, ok := defaultFormats[name]
+			if !ok {
+				l.addf("rejection: unknown format %q (expected %q, %q, %q or %q)", name, FormatJSON, FormatText, FormatHTML, FormatProblem)
+				continue
+			}
+			setString(&defaults.ContentType, format.ContentType)
+			setString(&defaults.Body, format.Body)
+			c.Rejection.Formats[name] = defaults
+		}
+	}
+
+	c.Rejection.Status = l.envInt("REJECTION_STATUS", c.Rejection.Status)
+	c.Rejection.Format = getEnv("REJECTION_FORMAT", c.Rejection.Format)
+
+	c.Rejection.parse(l)
+}
+
+// parse compiles the body templates. HTML bodies are escaped by html/template.
+func (rr *RejectionResponse) parse(l *loader) {
+	for _, name := range rr.FormatNames() {
+		format := rr.Formats[name]
+
+		var err error
+		if name == FormatHTML {
+			format.Template, err = htmltemplate.New(name).Parse(format.Body)
+		} else {
+			format.Template, err = texttemplate.New(name).Funcs(templateFuncs).Parse(format.Body)
+		}
+		if err != nil {
+			l.addf("rejection: invalid %s template: %v", name, err)
+			continue
+		}
+
+		rr.Formats[name] = format
+	}
+}
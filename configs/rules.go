@@ -0,0 +1,199 @@
+package configs
+
+import (
+	"strings"
+	"time"
+)
+
+// Rule precedence modes
+const (
+	// PrecedenceFirstMatch applies the first rule, in declaration order, that
+	// matches the request
+	PrecedenceFirstMatch = "first-match"
+
+	// PrecedenceMostSpecific applies the matching rule with the most path
+	// segments, preferring literal segments over wildcards and rules that
+	// restrict methods. Ties are broken by declaration order.
+	PrecedenceMostSpecific = "most-specific"
+)
+
+// Key strategies of a rule
+const (
+	// KeyAuto limits by token when the API_KEY header is present, by IP otherwise
+	KeyAuto = "auto"
+
+	// KeyIP always limits by client IP, ignoring tokens
+	KeyIP = "ip"
+
+	// KeyGlobal shares a single counter between every client
+	KeyGlobal = "global"
+)
+
+// Any other key strategy is a combination of key parts, see ParseKey
+
+// Rule describes a route-specific limit. Path is matched against the
+// beginning of the request path, segment by segment: "/api" matches "/api"
+// and "/api/users" but not "/apis". A "*" or "{name}" segment matches any
+// single segment, e.g. "/users/{id}/orders". Exact rules only match
+// paths with the same segments, e.g. "/health" but not "/health/x".
+type Rule struct {
+	Name      string   `yaml:"name" json:"name"`
+	Path      string   `yaml:"path" json:"path"`
+	Methods   []string `yaml:"methods" json:"methods"`
+	Limit     int      `yaml:"limit" json:"limit"`
+	Window    int      `yaml:"window" json:"window"`
+	BlockTime int      `yaml:"block_time" json:"block_time"`
+	Key       string   `yaml:"key" json:"key"`
+	Exact     bool     `yaml:"exact" json:"exact"`
+
+	// Exempt rules are never rate limited
+	Exempt bool `yaml:"exempt" json:"exempt"`
+
+	// Shadow rules are evaluated and counted but never reject requests
+	Shadow bool `yaml:"shadow" json:"shadow"`
+
+	// keyParts is Key parsed when the configuration is loaded, see ParsedKey
+	keyParts []KeyPart
+}
+
+// builtinRules are evaluated before the configured rules, so that probes
+// are never limited. They match their exact paths only, so that no other
+// route escapes the limits through them.
+var builtinRules = []Rule{
+	{Name: "health", Path: "/health", Exact: true, Exempt: true},
+	{Name: "livez", Path: "/livez", Exact: true, Exempt: true},
+	{Name: "readyz", Path: "/readyz", Exact: true, Exempt: true},
+}
+
+// MatchRule returns the rule that applies to a request. When no rule
+// matches, false is returned and the default IP/token limits apply.
+func (c *Config) MatchRule(method, path string) (Rule, bool) {
+	for _, rule := range builtinRules {
+		if _, ok := rule.match(method, path); ok {
+			return rule, true
+		}
+	}
+
+	best := -1
+	bestScore := -1
+
+	for i, rule := range c.Rules {
+		score, ok := rule.match(method, path)
+		if !ok {
+			continue
+		}
+
+		if c.RulePrecedence != PrecedenceMostSpecific {
+			return rule, true
+		}
+		if score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+
+	if best < 0 {
+		return Rule{}, false
+	}
+	return c.Rules[best], true
+}
+
+// WindowDuration returns the counting window of the rule (1 second by default)
+func (r Rule) WindowDuration() time.Duration {
+	if r.Window <= 0 {
+		return 1 * time.Second
+	}
+	return time.Duration(r.Window) * time.Second
+}
+
+// BlockDuration returns how long a key is blocked after exceeding the rule
+func (r Rule) BlockDuration() time.Duration {
+	return time.Duration(r.BlockTime) * time.Second
+}
+
+// KeyStrategy returns the key strategy of the rule, defaulting to KeyAuto
+func (r Rule) KeyStrategy() string {
+	if r.Key == "" {
+		return KeyAuto
+	}
+	return r.Key
+}
+
+// ParsedKey returns the key parts of the rule. Rules of a loaded
+// configuration are parsed once at load time; others, like rules built in
+// code, are parsed on every call. The key must be valid.
+func (r Rule) ParsedKey() []KeyPart {
+	if r.keyParts != nil {
+		return r.keyParts
+	}
+	parts, _ := ParseKey(r.Key)
+	return parts
+}
+
+// PathParam returns the segment of path matched by the "{name}" segment
+// of the rule path
+func (r Rule) PathParam(path, name string) (string, bool) {
+	pathSegments := SplitPath(path)
+	for i, segment := range SplitPath(r.Path) {
+		if segment == "{"+name+"}" && i < len(pathSegments) {
+			return pathSegments[i], true
+		}
+	}
+	return "", false
+}
+
+// match reports whether the rule applies to the request and how specific
+// the match is. The score orders matches by number of segments, then
+// number of literal segments, then method restriction.
+func (r Rule) match(method, path string) (int, bool) {
+	score := 0
+
+	if len(r.Methods) > 0 {
+		found := false
+		for _, m := range r.Methods {
+			if strings.EqualFold(m, method) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, false
+		}
+		score++
+	}
+
+	patternSegments := SplitPath(r.Path)
+	pathSegments := SplitPath(path)
+	if len(patternSegments) > len(pathSegments) {
+		return 0, false
+	}
+	if r.Exact && (len(patternSegments) != len(pathSegments) ||
+		strings.HasSuffix(path, "/") != strings.HasSuffix(r.Path, "/")) {
+		return 0, false
+	}
+
+	for i, segment := range patternSegments {
+		score += 1000
+		if isWildcard(segment) {
+			continue
+		}
+		if segment != pathSegments[i] {
+			return 0, false
+		}
+		score += 10
+	}
+
+	return score, true
+}
+
+// SplitPath returns the segments of a path, as rules and routes match them
+func SplitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+func isWildcard(segment string) bool {
+	return segment == "*" || (strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}"))
+}
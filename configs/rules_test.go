@@ -0,0 +1,161 @@
+package configs
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchRule_FirstMatch(t *testing.T) {
+	cfg := &Config{
+		RulePrecedence: PrecedenceFirstMatch,
+		Rules: []Rule{
+			{Name: "api", Path: "/api", Limit: 10, BlockTime: 1},
+			{Name: "login", Path: "/api/login", Methods: []string{"POST"}, Limit: 2, BlockTime: 1},
+		},
+	}
+
+	rule, ok := cfg.MatchRule("POST", "/api/login")
+	assert.True(t, ok)
+	assert.Equal(t, "api", rule.Name)
+
+	_, ok = cfg.MatchRule("GET", "/apis")
+	assert.False(t, ok)
+
+	_, ok = cfg.MatchRule("GET", "/")
+	assert.False(t, ok)
+}
+
+func TestMatchRule_MostSpecific(t *testing.T) {
+	cfg := &Config{
+		RulePrecedence: PrecedenceMostSpecific,
+		Rules: []Rule{
+			{Name: "all", Path: "/", Limit: 100, BlockTime: 1},
+			{Name: "api", Path: "/api", Limit: 10, BlockTime: 1},
+			{Name: "user", Path: "/api/users/{id}", Limit: 5, BlockTime: 1},
+			{Name: "me", Path: "/api/users/me", Limit: 3, BlockTime: 1},
+			{Name: "login", Path: "/api/login", Methods: []string{"post"}, Limit: 2, BlockTime: 1},
+			{Name: "any-login", Path: "/api/login", Limit: 4, BlockTime: 1},
+		},
+	}
+
+	tests := []struct {
+		method, path, expected string
+	}{
+		{"GET", "/", "all"},
+		{"GET", "/other", "all"},
+		{"GET", "/api", "api"},
+		{"GET", "/api/users/42", "user"},
+		{"GET", "/api/users/42/orders", "user"},
+		{"GET", "/api/users/me", "me"},
+		{"POST", "/api/login", "login"},
+		{"GET", "/api/login", "any-login"},
+		{"GET", "/health", "health"},
+	}
+
+	for _, tt := range tests {
+		rule, ok := cfg.MatchRule(tt.method, tt.path)
+		assert.True(t, ok, "%s %s should match a rule", tt.method, tt.path)
+		assert.Equal(t, tt.expected, rule.Name, "%s %s", tt.method, tt.path)
+	}
+}
+
+func TestMatchRule_BuiltinHealth(t *testing.T) {
+	cfg := &Config{}
+
+	rule, ok := cfg.MatchRule("GET", "/health")
+	assert.True(t, ok)
+	assert.True(t, rule.Exempt)
+
+	// Built-in rules match their exact path only
+	for _, path := range []string{"/health/x", "/health/", "/livez/x", "/readyz/x"} {
+		_, ok = cfg.MatchRule("GET", path)
+		assert.False(t, ok, path)
+	}
+
+	// Built-in rules are evaluated before the configured ones, whatever
+	// the precedence, so probes are never limited
+	cfg.Rules = []Rule{
+		{Name: "all", Path: "/", Limit: 1, BlockTime: 1},
+		{Name: "health-limited", Path: "/health", Limit: 1, BlockTime: 1},
+	}
+	for _, precedence := range []string{PrecedenceFirstMatch, PrecedenceMostSpecific} {
+		cfg.RulePrecedence = precedence
+		for _, path := range []string{"/health", "/livez", "/readyz"} {
+			rule, ok = cfg.MatchRule("GET", path)
+			assert.True(t, ok)
+			assert.True(t, rule.Exempt, "%s %s", precedence, path)
+		}
+	}
+
+	cfg.RulePrecedence = PrecedenceFirstMatch
+	rule, ok = cfg.MatchRule("GET", "/health/x")
+	assert.True(t, ok)
+	assert.Equal(t, "all", rule.Name)
+}
+
+func TestMatchRule_Exact(t *testing.T) {
+	cfg := &Config{
+		Rules: []Rule{{Name: "users", Path: "/users/{id}", Exact: true, Limit: 1, BlockTime: 1}},
+	}
+
+	rule, ok := cfg.MatchRule("GET", "/users/42")
+	assert.True(t, ok)
+	assert.Equal(t, "users", rule.Name)
+
+	_, ok = cfg.MatchRule("GET", "/users/42/orders")
+	assert.False(t, ok)
+}
+
+func TestLoadConfig_ParsesRuleKeys(t *testing.T) {
+	os.Clearenv()
+
+	path := writeConfigFile(t, "config.yaml", `
+rules:
+  - name: tenants
+    path: /api
+    limit: 10
+    block_time: 60
+    key: header:X-Tenant+ip
+`)
+	os.Setenv("CONFIG_FILE", path)
+	defer os.Unsetenv("CONFIG_FILE")
+
+	cfg, err := LoadConfig()
+	assert.NoError(t, err)
+
+	expected := []KeyPart{{Kind: KeyPartHeader, Arg: "X-Tenant"}, {Kind: KeyPartIP}}
+	assert.Equal(t, expected, cfg.Rules[0].keyParts)
+	assert.Equal(t, expected, cfg.Rules[0].ParsedKey())
+
+	// Rules built in code are parsed on use
+	assert.Equal(t, expected, Rule{Key: "header:X-Tenant+ip"}.ParsedKey())
+}
+
+func TestRule_Defaults(t *testing.T) {
+	rule := Rule{BlockTime: 30}
+	assert.Equal(t, 1*time.Second, rule.WindowDuration())
+	assert.Equal(t, 30*time.Second, rule.BlockDuration())
+	assert.Equal(t, KeyAuto, rule.KeyStrategy())
+
+	rule = Rule{Window: 60, Key: KeyIP}
+	assert.Equal(t, 60*time.Second, rule.WindowDuration())
+	assert.Equal(t, KeyIP, rule.KeyStrategy())
+}
+
+func TestRule_PathParam(t *testing.T) {
+	rule := Rule{Path: "/tenants/{tenant}/orders/{id}"}
+
+	value, ok := rule.PathParam("/tenants/acme/orders/42/items", "tenant")
+	assert.True(t, ok)
+	assert.Equal(t, "acme", value)
+
+	value, ok = rule.PathParam("/tenants/acme/orders/42", "id")
+	assert.True(t, ok)
+	assert.Equal(t, "42", value)
+
+	_, ok = rule.PathParam("/tenants/acme/orders/42", "user")
+	assert.False(t, ok)
+}
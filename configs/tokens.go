@@ -0,0 +1,163 @@
+package configs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// hashPrefix marks a token value that is already a SHA-256 hash
+const hashPrefix = "sha256:"
+
+// HashToken returns the hex-encoded SHA-256 hash of a token. Tokens are
+// only kept, compared and used in limiter keys by their hash, so raw
+// secrets never reach logs or storage.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ShortHash returns a prefix of a token hash, enough to tell tokens
+// apart in logs
+func ShortHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
+
+// IsTokenHash reports whether s is a token hash as returned by HashToken
+func IsTokenHash(s string) bool {
+	decoded, err := hex.DecodeString(s)
+	return err == nil && len(decoded) == sha256.Size && strings.ToLower(s) == s
+}
+
+// tokenHash returns the hash of a configured token: "sha256:<hex>" values
+// are taken as already hashed, anything else is hashed
+func tokenHash(value string) (string, error) {
+	hash, ok := strings.CutPrefix(value, hashPrefix)
+	if !ok {
+		return HashToken(value), nil
+	}
+
+	hash = strings.ToLower(hash)
+	if !IsTokenHash(hash) {
+		return "", errors.New("is not a valid SHA-256 hash")
+	}
+	return hash, nil
+}
+
+// tokenSet hashes a list of configured tokens
+func (l *loader) tokenSet(key string, values map[string]bool) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, value := range sortedKeys(values) {
+		hash, err := tokenHash(value)
+		if err != nil {
+			l.addf("%s: an entry %v", key, err)
+			continue
+		}
+		set[hash] = true
+	}
+	return set
+}
+
+// loadTokensFile reads the token registry file pointed to by TOKENS_FILE:
+// a YAML or JSON list of tokens, with the same fields as the tokens of
+// the configuration file. Entries should carry a hash rather than a value.
+func loadTokensFile(path string) ([]fileToken, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tokens file: %w", err)
+	}
+
+	var tokens []fileToken
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&tokens)
+	default:
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&tokens)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse tokens file %s: %w", path, err)
+	}
+
+	return tokens, nil
+}
+
+// resolveTokens resolves the limits of declared tokens, indexed by hash.
+// Explicit limits win over the tier, which wins over the default tier.
+// source prefixes problems, to tell the files apart.
+func resolveTokens(source string, declared []fileToken, cfg *Config, l *loader) map[string]TokenConfig {
+	tokens := make(map[string]TokenConfig, len(declared))
+	seen := make(map[string]string, len(declared))
+
+	for i, token := range declared {
+		label := fmt.Sprintf("token #%d", i+1)
+		if token.Name != "" {
+			label = fmt.Sprintf("token %q", token.Name)
+		}
+		if source != "" {
+			label = source + " " + label
+		}
+
+		value := token.Value
+		switch {
+		case token.Hash != "" && token.Value != "":
+			l.addf("%s must have either a value or a hash, not both", label)
+			continue
+		case token.Hash != "":
+			value = hashPrefix + token.Hash
+		case token.Value == "":
+			l.addf("%s has no value or hash", label)
+			continue
+		}
+
+		hash, err := tokenHash(value)
+		if err != nil {
+			l.addf("%s hash %v", label, err)
+			continue
+		}
+		if other, ok := seen[hash]; ok {
+			l.addf("%s and %s have the same value", other, label)
+			continue
+		}
+		seen[hash] = label
+
+		tier := token.Tier
+		if tier == "" {
+			tier = DefaultTier
+		}
+		tc, ok := cfg.GetTierConfig(tier)
+		if !ok {
+			l.addf("%s refers to unknown tier %q", label, token.Tier)
+		}
+
+		tc.Name = token.Name
+		setInt(&tc.Limit, token.Limit)
+		setInt(&tc.BlockTime, token.BlockTime)
+
+		// Inherited values are already checked where they are declared
+		if token.Limit != nil {
+			l.positive(label+" limit", tc.Limit)
+		}
+		if token.BlockTime != nil {
+			l.positive(label+" block_time", tc.BlockTime)
+		}
+
+		tokens[hash] = tc
+	}
+
+	return tokens
+}
@@ -0,0 +1,272 @@
+package configs
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ValidationError reports every problem found in a configuration at once,
+// so they can all be fixed before the next attempt.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "invalid configuration (%d problems):", len(e.Problems))
+	for _, problem := range e.Problems {
+		b.WriteString("\n  - ")
+		b.WriteString(problem)
+	}
+	return b.String()
+}
+
+// loader collects problems found while reading raw values
+type loader struct {
+	problems []string
+}
+
+func (l *loader) addf(format string, args ...any) {
+	l.problems = append(l.problems, fmt.Sprintf(format, args...))
+}
+
+// envInt reads an integer environment variable, recording a problem (and
+// returning the default) when the value is not a valid integer.
+func (l *loader) envInt(key string, defaultValue int) int {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+
+	value, err := strconv.Atoi(strings.TrimSpace(valueStr))
+	if err != nil {
+		l.addf("%s: %q is not a valid integer", key, valueStr)
+		return defaultValue
+	}
+
+	return value
+}
+
+// envBool reads a boolean environment variable (true/false, 1/0...),
+// recording a problem (and returning the default) when it cannot be parsed.
+func (l *loader) envBool(key string, defaultValue bool) bool {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+
+	value, err := strconv.ParseBool(strings.TrimSpace(valueStr))
+	if err != nil {
+		l.addf("%s: %q is not a valid boolean", key, valueStr)
+		return defaultValue
+	}
+
+	return value
+}
+
+// envFloat reads a floating point environment variable, recording a
+// problem (and returning the default) when it cannot be parsed.
+func (l *loader) envFloat(key string, defaultValue float64) float64 {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(valueStr), 64)
+	if err != nil {
+		l.addf("%s: %q is not a valid number", key, valueStr)
+		return defaultValue
+	}
+
+	return value
+}
+
+func (l *loader) positive(name string, value int) {
+	if value <= 0 {
+		l.addf("%s must be positive, got %d", name, value)
+	}
+}
+
+// validate checks the semantic rules of a fully loaded configuration.
+// Tokens are checked while loading, where their names are still known.
+func (c *Config) validate() []string {
+	l := &loader{}
+
+	l.positive("RATE_LIMIT_IP", c.RateLimitIP)
+	l.positive("RATE_LIMIT_IP_BLOCK_TIME", c.RateLimitIPBlockTime)
+	l.positive("RATE_LIMIT_TOKEN", c.RateLimitToken)
+	l.positive("RATE_LIMIT_TOKEN_BLOCK_TIME", c.RateLimitTokenBlockTime)
+	l.positive("RATE_LIMIT_TOKEN_IP", c.RateLimitTokenIP)
+	l.positive("RATE_LIMIT_TOKEN_IP_BLOCK_TIME", c.RateLimitTokenIPBlockTime)
+	l.positive("SHUTDOWN_TIMEOUT", c.ShutdownTimeout)
+
+	if c.RedisDB < 0 {
+		l.addf("REDIS_DB must not be negative, got %d", c.RedisDB)
+	}
+	if c.ConfigReloadInterval < 0 {
+		l.addf("CONFIG_RELOAD_INTERVAL must not be negative, got %d", c.ConfigReloadInterval)
+	}
+	if c.ShutdownDrainDelay < 0 {
+		l.addf("SHUTDOWN_DRAIN_DELAY must not be negative, got %d", c.ShutdownDrainDelay)
+	}
+
+	for _, name := range sortedKeys(c.Tiers) {
+		tier := c.Tiers[name]
+		label := fmt.Sprintf("tier %q", name)
+
+		// The limits of the default tier are checked as RATE_LIMIT_TOKEN*
+		if name != DefaultTier {
+			l.positive(label+" limit", tier.Limit)
+			l.positive(label+" block_time", tier.BlockTime)
+		}
+		if tier.Window < 0 {
+			l.addf("%s window must not be negative, got %d", label, tier.Window)
+		}
+		if tier.Quota < 0 {
+			l.addf("%s quota must not be negative, got %d", label, tier.Quota)
+		}
+		if tier.QuotaWindow < 0 {
+			l.addf("%s quota_window must not be negative, got %d", label, tier.QuotaWindow)
+		}
+	}
+
+	if c.RulePrecedence != PrecedenceFirstMatch && c.RulePrecedence != PrecedenceMostSpecific {
+		l.addf("RULE_PRECEDENCE must be %q or %q, got %q", PrecedenceFirstMatch, PrecedenceMostSpecific, c.RulePrecedence)
+	}
+
+	switch c.RateLimitHeaders {
+	case HeadersLegacy, HeadersDraft, HeadersBoth, HeadersNone:
+	default:
+		l.addf("RATE_LIMIT_HEADERS must be one of %q, %q, %q or %q, got %q",
+			HeadersLegacy, HeadersDraft, HeadersBoth, HeadersNone, c.RateLimitHeaders)
+	}
+
+	if c.Rejection != nil {
+		if c.Rejection.Status < 400 || c.Rejection.Status > 599 {
+			l.addf("REJECTION_STATUS must be between 400 and 599, got %d", c.Rejection.Status)
+		}
+		if _, ok := c.Rejection.Formats[c.Rejection.Format]; !ok {
+			l.addf("REJECTION_FORMAT must be one of %q, %q, %q or %q, got %q",
+				FormatJSON, FormatText, FormatHTML, FormatProblem, c.Rejection.Format)
+		}
+	}
+
+	switch c.UnknownTokenPolicy {
+	case UnknownTokenDefault, UnknownTokenReject, UnknownTokenIP:
+	case UnknownTokenUnregistered:
+		if _, ok := c.Tiers[UnregisteredTier]; !ok {
+			l.addf("UNKNOWN_TOKEN_POLICY %q requires an %q tier", UnknownTokenUnregistered, UnregisteredTier)
+		}
+	default:
+		l.addf("UNKNOWN_TOKEN_POLICY must be one of %q, %q, %q or %q, got %q",
+			UnknownTokenDefault, UnknownTokenReject, UnknownTokenIP, UnknownTokenUnregistered, c.UnknownTokenPolicy)
+	}
+
+	for _, limit := range sortedKeys(c.CombinedLimits) {
+		if limit != CombinedIP && limit != CombinedTokenIP {
+			l.addf("COMBINED_LIMITS: unknown limit %q (expected %q or %q)", limit, CombinedIP, CombinedTokenIP)
+		}
+	}
+
+	if c.LogFormat != LogFormatText && c.LogFormat != LogFormatJSON {
+		l.addf("LOG_FORMAT must be %q or %q, got %q", LogFormatText, LogFormatJSON, c.LogFormat)
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		l.addf("LOG_LEVEL must be one of \"debug\", \"info\", \"warn\" or \"error\", got %q", c.LogLevel)
+	}
+	if c.TracingExporter != TracingNone && c.TracingExporter != TracingStdout && c.TracingExporter != TracingOTLP {
+		l.addf("TRACING_EXPORTER must be %q, %q or %q, got %q", TracingNone, TracingStdout, TracingOTLP, c.TracingExporter)
+	}
+	if c.TracingExporter == TracingOTLP {
+		if u, err := url.Parse(c.TracingEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			l.addf("OTEL_EXPORTER_OTLP_ENDPOINT must be an http or https URL, got %q", c.TracingEndpoint)
+		}
+	}
+	if c.TracingExporter != TracingNone && c.TracingServiceName == "" {
+		l.addf("OTEL_SERVICE_NAME must not be empty when tracing is enabled")
+	}
+	if c.DecisionLogSample < 0 || c.DecisionLogSample > 1 {
+		l.addf("DECISION_LOG_SAMPLE must be between 0 and 1, got %g", c.DecisionLogSample)
+	}
+
+	switch c.ClientIPSource {
+	case ClientIPAuto, ClientIPForwarded, ClientIPXForwardedFor, ClientIPXRealIP, ClientIPRemoteAddr:
+	default:
+		l.addf("CLIENT_IP_SOURCE must be one of %q, %q, %q, %q or %q, got %q",
+			ClientIPAuto, ClientIPForwarded, ClientIPXForwardedFor, ClientIPXRealIP, ClientIPRemoteAddr, c.ClientIPSource)
+	}
+
+	if c.IPv4Prefix < 1 || c.IPv4Prefix > 32 {
+		l.addf("IP_V4_PREFIX must be between 1 and 32, got %d", c.IPv4Prefix)
+	}
+	if c.IPv6Prefix < 1 || c.IPv6Prefix > 128 {
+		l.addf("IP_V6_PREFIX must be between 1 and 128, got %d", c.IPv6Prefix)
+	}
+
+	if c.Gateway != nil {
+		l.positive("GATEWAY_TIMEOUT", c.Gateway.Timeout)
+
+		for i, route := range c.Gateway.Routes {
+			label := routeLabel(i, route)
+			if route.Path == "" {
+				l.addf("%s must have a path", label)
+			}
+			if route.Timeout < 0 {
+				l.addf("%s timeout must not be negative, got %d", label, route.Timeout)
+			}
+		}
+	}
+
+	for _, token := range sortedKeys(c.AllowedTokens) {
+		if c.DeniedTokens[token] {
+			l.addf("a token is listed in both ALLOW_TOKENS and DENY_TOKENS")
+			break
+		}
+	}
+
+	names := make(map[string]bool)
+	for i, rule := range c.Rules {
+		label := fmt.Sprintf("rule #%d", i+1)
+		if rule.Name == "" {
+			l.addf("%s must have a name", label)
+		} else {
+			label = fmt.Sprintf("rule %q", rule.Name)
+			if names[rule.Name] {
+				l.addf("%s is declared more than once", label)
+			}
+			names[rule.Name] = true
+		}
+
+		if rule.Path == "" {
+			l.addf("%s must have a path", label)
+		}
+
+		// Exempt rules carry no limits
+		if rule.Exempt {
+			continue
+		}
+
+		l.positive(label+" limit", rule.Limit)
+		l.positive(label+" block_time", rule.BlockTime)
+		if rule.Window < 0 {
+			l.addf("%s window must not be negative, got %d", label, rule.Window)
+		}
+
+		parts, err := ParseKey(rule.Key)
+		if err != nil {
+			l.addf("%s has invalid key strategy %q: %v", label, rule.Key, err)
+		}
+		for _, part := range parts {
+			if part.Kind == KeyPartPath && !strings.Contains(rule.Path, "{"+part.Arg+"}") {
+				l.addf("%s key uses path parameter %q, which is not in its path", label, part.Arg)
+			}
+		}
+	}
+
+	return l.problems
+}
@@ -0,0 +1,303 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/configs"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/limiter"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/storage"
+)
+
+type Handler struct {
+	limiter *limiter.RateLimiter
+	token   string
+	mux     *http.ServeMux
+}
+
+type blockResponse struct {
+	Key       string    `json:"key"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type keyResponse struct {
+	Key     string `json:"key"`
+	Count   int64  `json:"count"`
+	Blocked bool   `json:"blocked"`
+}
+
+type overrideRequest struct {
+	Limit     int `json:"limit"`
+	BlockTime int `json:"block_time"`
+	TTL       int `json:"ttl"`
+}
+
+type tokenResponse struct {
+	Hash string `json:"hash"`
+	storage.TokenRecord
+}
+
+type overrideResponse struct {
+	Key       string    `json:"key"`
+	Limit     int       `json:"limit"`
+	BlockTime int       `json:"block_time"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// NewHandler returns the admin API. Every request must carry the given
+// token as a bearer token in the Authorization header.
+func NewHandler(rateLimiter *limiter.RateLimiter, token string) http.Handler {
+	h := &Handler{
+		limiter: rateLimiter,
+		token:   token,
+		mux:     http.NewServeMux(),
+	}
+
+	h.mux.HandleFunc("GET /admin/blocks", h.listBlocks)
+	h.mux.HandleFunc("DELETE /admin/blocks/{key}", h.unblock)
+	h.mux.HandleFunc("GET /admin/keys/{key}", h.getKey)
+	h.mux.HandleFunc("DELETE /admin/keys/{key}", h.resetKey)
+	h.mux.HandleFunc("GET /admin/overrides", h.listOverrides)
+	h.mux.HandleFunc("PUT /admin/overrides/{hash}", h.setOverride)
+	h.mux.HandleFunc("DELETE /admin/overrides/{hash}", h.removeOverride)
+	h.mux.HandleFunc("GET /admin/tokens", h.listTokens)
+	h.mux.HandleFunc("PUT /admin/tokens/{hash}", h.setToken)
+	h.mux.HandleFunc("DELETE /admin/tokens/{hash}", h.removeToken)
+
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		h.mux.ServeHTTP(w, r)
+		return
+	}
+
+	// Log every mutating call along with its outcome
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	h.mux.ServeHTTP(rec, r)
+	slog.InfoContext(r.Context(), "Admin request",
+		"method", r.Method,
+		"path", r.URL.Path,
+		"remote_addr", r.RemoteAddr,
+		"status", rec.status,
+	)
+}
+
+func (h *Handler) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || h.token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) == 1
+}
+
+func (h *Handler) listBlocks(w http.ResponseWriter, r *http.Request) {
+	blocks, err := h.limiter.ListBlocks(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response := make([]blockResponse, 0, len(blocks))
+	for _, block := range blocks {
+		response = append(response, blockResponse{Key: block.Key, ExpiresAt: block.ExpiresAt})
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (h *Handler) unblock(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	if err := h.limiter.Unblock(r.Context(), key); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"key": key, "status": "unblocked"})
+}
+
+func (h *Handler) getKey(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	key := r.PathValue("key")
+
+	count, err := h.limiter.GetCurrentCount(ctx, key)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	blocked, err := h.limiter.IsBlocked(ctx, key)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, keyResponse{Key: key, Count: count, Blocked: blocked})
+}
+
+func (h *Handler) resetKey(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	if err := h.limiter.Reset(r.Context(), key); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"key": key, "status": "reset"})
+}
+
+func (h *Handler) listOverrides(w http.ResponseWriter, r *http.Request) {
+	overrides := h.limiter.Overrides()
+
+	response := make([]overrideResponse, 0, len(overrides))
+	for key, override := range overrides {
+		response = append(response, newOverrideResponse(key, override))
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (h *Handler) setOverride(w http.ResponseWriter, r *http.Request) {
+	var req overrideRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if req.Limit <= 0 || req.BlockTime <= 0 || req.TTL <= 0 {
+		writeError(w, http.StatusBadRequest, "limit, block_time and ttl must be positive")
+		return
+	}
+
+	hash, ok := tokenHash(w, r)
+	if !ok {
+		return
+	}
+
+	key := "token:" + hash
+	override := h.limiter.SetOverride(
+		key,
+		req.Limit,
+		time.Duration(req.BlockTime)*time.Second,
+		time.Duration(req.TTL)*time.Second,
+	)
+
+	writeJSON(w, http.StatusOK, newOverrideResponse(key, override))
+}
+
+func (h *Handler) removeOverride(w http.ResponseWriter, r *http.Request) {
+	hash, ok := tokenHash(w, r)
+	if !ok {
+		return
+	}
+
+	key := "token:" + hash
+	h.limiter.RemoveOverride(key)
+
+	writeJSON(w, http.StatusOK, map[string]string{"key": key, "status": "removed"})
+}
+
+func (h *Handler) listTokens(w http.ResponseWriter, r *http.Request) {
+	tokens, err := h.limiter.ListTokens(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response := make([]tokenResponse, 0, len(tokens))
+	for hash, record := range tokens {
+		response = append(response, tokenResponse{Hash: hash, TokenRecord: record})
+	}
+	sort.Slice(response, func(i, j int) bool { return response[i].Hash < response[j].Hash })
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (h *Handler) setToken(w http.ResponseWriter, r *http.Request) {
+	hash, ok := tokenHash(w, r)
+	if !ok {
+		return
+	}
+
+	var record storage.TokenRecord
+	if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if record.Limit < 0 || record.BlockTime < 0 {
+		writeError(w, http.StatusBadRequest, "limit and block_time must not be negative")
+		return
+	}
+
+	if err := h.limiter.RegisterToken(r.Context(), hash, record); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tokenResponse{Hash: hash, TokenRecord: record})
+}
+
+func (h *Handler) removeToken(w http.ResponseWriter, r *http.Request) {
+	hash, ok := tokenHash(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.limiter.RemoveToken(r.Context(), hash); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"hash": hash, "status": "removed"})
+}
+
+// tokenHash reads the token hash of the path. Raw tokens are refused so
+// that they never end up in URLs and logs.
+func tokenHash(w http.ResponseWriter, r *http.Request) (string, bool) {
+	hash := r.PathValue("hash")
+	if !configs.IsTokenHash(hash) {
+		writeError(w, http.StatusBadRequest, "expected the SHA-256 hash of the token (64 lowercase hex characters)")
+		return "", false
+	}
+	return hash, true
+}
+
+func newOverrideResponse(key string, override limiter.Override) overrideResponse {
+	return overrideResponse{
+		Key:       key,
+		Limit:     override.Limit,
+		BlockTime: int(override.BlockDuration / time.Second),
+		ExpiresAt: override.ExpiresAt,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,151 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/configs"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/limiter"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestHandler() (http.Handler, *limiter.RateLimiter) {
+	store := storage.NewMemoryStorage()
+	rateLimiter := limiter.NewRateLimiter(store)
+	return NewHandler(rateLimiter, "secret"), rateLimiter
+}
+
+func doRequest(handler http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestAdmin_Unauthorized(t *testing.T) {
+	handler, _ := newTestHandler()
+
+	req := httptest.NewRequest("GET", "/admin/blocks", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	req = httptest.NewRequest("GET", "/admin/blocks", nil)
+	req.Header.Set("Authorization", "Bearer wrong")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestAdmin_BlocksAndUnblock(t *testing.T) {
+	handler, rateLimiter := newTestHandler()
+	ctx := context.Background()
+
+	// Exceed the limit to create a block
+	for i := 0; i <= 2; i++ {
+		rateLimiter.AllowRequest(ctx, "ip:10.0.0.1", 2, 1*time.Minute)
+	}
+
+	w := doRequest(handler, "GET", "/admin/blocks", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var blocks []blockResponse
+	json.NewDecoder(w.Body).Decode(&blocks)
+	assert.Len(t, blocks, 1)
+	assert.Equal(t, "ip:10.0.0.1", blocks[0].Key)
+
+	w = doRequest(handler, "DELETE", "/admin/blocks/ip:10.0.0.1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	blocked, err := rateLimiter.IsBlocked(ctx, "ip:10.0.0.1")
+	assert.NoError(t, err)
+	assert.False(t, blocked)
+}
+
+func TestAdmin_GetAndResetKey(t *testing.T) {
+	handler, rateLimiter := newTestHandler()
+	ctx := context.Background()
+
+	rateLimiter.AllowRequest(ctx, "token:abc123", 10, 1*time.Minute)
+	rateLimiter.AllowRequest(ctx, "token:abc123", 10, 1*time.Minute)
+
+	w := doRequest(handler, "GET", "/admin/keys/token:abc123", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var key keyResponse
+	json.NewDecoder(w.Body).Decode(&key)
+	assert.Equal(t, int64(2), key.Count)
+	assert.False(t, key.Blocked)
+
+	w = doRequest(handler, "DELETE", "/admin/keys/token:abc123", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	count, err := rateLimiter.GetCurrentCount(ctx, "token:abc123")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestAdmin_Overrides(t *testing.T) {
+	handler, rateLimiter := newTestHandler()
+	hash := configs.HashToken("abc123")
+
+	w := doRequest(handler, "PUT", "/admin/overrides/"+hash, `{"limit": 5, "block_time": 60, "ttl": 3600}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var override overrideResponse
+	json.NewDecoder(w.Body).Decode(&override)
+	assert.Equal(t, "token:"+hash, override.Key)
+	assert.Equal(t, 5, override.Limit)
+	assert.Equal(t, 60, override.BlockTime)
+
+	assert.Contains(t, rateLimiter.Overrides(), "token:"+hash)
+
+	w = doRequest(handler, "PUT", "/admin/overrides/"+hash, `{"limit": 0}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Raw tokens are refused
+	w = doRequest(handler, "PUT", "/admin/overrides/abc123", `{"limit": 5, "block_time": 60, "ttl": 3600}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = doRequest(handler, "DELETE", "/admin/overrides/"+hash, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, rateLimiter.Overrides())
+}
+
+func TestAdmin_Tokens(t *testing.T) {
+	handler, rateLimiter := newTestHandler()
+	ctx := context.Background()
+	hash := configs.HashToken("abc123")
+
+	w := doRequest(handler, "PUT", "/admin/tokens/"+hash, `{"name": "acme", "tier": "pro"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	record, found, err := rateLimiter.LookupToken(ctx, hash)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, storage.TokenRecord{Name: "acme", Tier: "pro"}, record)
+
+	w = doRequest(handler, "GET", "/admin/tokens", "")
+	var tokens []tokenResponse
+	json.NewDecoder(w.Body).Decode(&tokens)
+	assert.Equal(t, []tokenResponse{{Hash: hash, TokenRecord: record}}, tokens)
+
+	w = doRequest(handler, "PUT", "/admin/tokens/abc123", `{"tier": "pro"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = doRequest(handler, "PUT", "/admin/tokens/"+hash, `{"limit": -1}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = doRequest(handler, "DELETE", "/admin/tokens/"+hash, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	_, found, _ = rateLimiter.LookupToken(ctx, hash)
+	assert.False(t, found)
+}
@@ -0,0 +1,155 @@
+// Package gateway forwards the requests that pass the rate limiter to the
+// upstream services configured for them.
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/configs"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Handler proxies every request to the upstream of the route it matches,
+// or to the default upstream of the gateway. The configuration is read on
+// every request, so routes follow configuration reloads.
+type Handler struct {
+	cfg    configs.Provider
+	logger *slog.Logger
+	proxy  *httputil.ReverseProxy
+}
+
+// proxyTarget is where a request is sent, passed from ServeHTTP to the
+// proxy through the request context
+type proxyTarget struct {
+	cfg      *configs.Config
+	route    configs.Route
+	upstream *url.URL
+}
+
+type targetKey struct{}
+
+// NewHandler returns the gateway handler
+func NewHandler(cfg configs.Provider, logger *slog.Logger) http.Handler {
+	h := &Handler{cfg: cfg, logger: logger}
+	h.proxy = &httputil.ReverseProxy{
+		Rewrite:      h.rewrite,
+		Transport:    http.DefaultTransport.(*http.Transport).Clone(),
+		ErrorHandler: h.handleError,
+	}
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	cfg := h.cfg.Current()
+	if cfg.Gateway == nil {
+		writeError(w, http.StatusBadGateway, "gateway mode is not configured")
+		return
+	}
+
+	route, _ := cfg.Gateway.MatchRoute(r.Method, r.URL.Path, cfg.RulePrecedence)
+	upstream := route.Target
+	if upstream == nil {
+		upstream = cfg.Gateway.Upstream
+	}
+	if upstream == nil {
+		writeError(w, http.StatusNotFound, "no upstream for this path")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(cfg.Gateway.TimeoutFor(route))*time.Second)
+	defer cancel()
+
+	ctx = context.WithValue(ctx, targetKey{}, &proxyTarget{cfg: cfg, route: route, upstream: upstream})
+	h.proxy.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// rewrite builds the upstream request. Hop-by-hop and forwarding headers
+// of the client have already been removed by the proxy.
+func (h *Handler) rewrite(pr *httputil.ProxyRequest) {
+	target := pr.In.Context().Value(targetKey{}).(*proxyTarget)
+	gw := target.cfg.Gateway
+
+	if target.route.StripPrefix {
+		escaped := stripSegments(pr.In.URL.EscapedPath(), len(configs.SplitPath(target.route.Path)))
+		pr.Out.URL.RawPath = escaped
+		pr.Out.URL.Path, _ = url.PathUnescape(escaped)
+	}
+	pr.SetURL(target.upstream)
+
+	if gw.PreserveHost {
+		pr.Out.Host = pr.In.Host
+	}
+
+	// Forwarding headers are only kept when they come from a trusted proxy,
+	// so that clients cannot forge the chain seen by the upstream
+	trusted := false
+	if remote, ok := middleware.RemoteAddr(pr.In); ok {
+		trusted = target.cfg.IsTrustedProxy(remote)
+	}
+	if trusted {
+		pr.Out.Header["X-Forwarded-For"] = pr.In.Header["X-Forwarded-For"]
+	}
+	pr.SetXForwarded()
+	if trusted {
+		for _, name := range []string{"X-Forwarded-Host", "X-Forwarded-Proto"} {
+			if value := pr.In.Header.Get(name); value != "" {
+				pr.Out.Header.Set(name, value)
+			}
+		}
+	}
+
+	for name, value := range gw.Headers {
+		pr.Out.Header.Set(name, value)
+	}
+
+	// Continue the trace of the rate limiter in the upstream
+	otel.GetTextMapPropagator().Inject(pr.Out.Context(), propagation.HeaderCarrier(pr.Out.Header))
+}
+
+// handleError answers requests the upstream did not: 504 when the timeout
+// of the route elapsed, 502 otherwise
+func (h *Handler) handleError(w http.ResponseWriter, r *http.Request, err error) {
+	target := r.Context().Value(targetKey{}).(*proxyTarget)
+
+	status, message := http.StatusBadGateway, "upstream unavailable"
+	if errors.Is(err, context.DeadlineExceeded) {
+		status, message = http.StatusGatewayTimeout, "upstream timed out"
+	}
+
+	h.logger.WarnContext(r.Context(), "Upstream request failed",
+		"upstream", target.upstream.Redacted(),
+		"route", target.route.Name,
+		"status", status,
+		"error", err,
+	)
+	writeError(w, status, message)
+}
+
+// stripSegments removes the first n segments of path
+func stripSegments(path string, n int) string {
+	rest := strings.TrimPrefix(path, "/")
+	for i := 0; i < n && rest != ""; i++ {
+		if j := strings.IndexByte(rest, '/'); j >= 0 {
+			rest = rest[j+1:]
+		} else {
+			rest = ""
+		}
+	}
+	return "/" + rest
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
@@ -0,0 +1,196 @@
+package gateway
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/configs"
+	"github.com/stretchr/testify/assert"
+)
+
+// echoUpstream answers with the path, host and headers it received
+func echoUpstream(t *testing.T, name string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"upstream": name,
+			"path":     r.URL.Path,
+			"query":    r.URL.RawQuery,
+			"host":     r.Host,
+			"headers":  r.Header,
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func mustParse(t *testing.T, raw string) *url.URL {
+	u, err := url.Parse(raw)
+	assert.NoError(t, err)
+	return u
+}
+
+type echoed struct {
+	Upstream string      `json:"upstream"`
+	Path     string      `json:"path"`
+	Query    string      `json:"query"`
+	Host     string      `json:"host"`
+	Headers  http.Header `json:"headers"`
+}
+
+func doRequest(t *testing.T, handler http.Handler, r *http.Request) (*httptest.ResponseRecorder, echoed) {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	var body echoed
+	if w.Code == http.StatusOK {
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	}
+	return w, body
+}
+
+func newHandler(cfg *configs.Config) http.Handler {
+	return NewHandler(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestGateway_Routes(t *testing.T) {
+	primary := echoUpstream(t, "main")
+	users := echoUpstream(t, "users")
+
+	cfg := &configs.Config{
+		Gateway: &configs.Gateway{
+			Upstream: mustParse(t, primary.URL+"/base"),
+			Timeout:  5,
+			Routes: []configs.Route{
+				{Name: "users", Path: "/users", Methods: []string{"GET"}, Target: mustParse(t, users.URL), StripPrefix: true},
+			},
+		},
+	}
+	handler := newHandler(cfg)
+
+	w, body := doRequest(t, handler, httptest.NewRequest("GET", "/users/42?full=1", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "users", body.Upstream)
+	assert.Equal(t, "/42", body.Path)
+	assert.Equal(t, "full=1", body.Query)
+
+	// Other methods and paths go to the default upstream, under its path
+	w, body = doRequest(t, handler, httptest.NewRequest("POST", "/users/42", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "main", body.Upstream)
+	assert.Equal(t, "/base/users/42", body.Path)
+
+	_, body = doRequest(t, handler, httptest.NewRequest("GET", "/orders", nil))
+	assert.Equal(t, "main", body.Upstream)
+}
+
+func TestGateway_NoUpstream(t *testing.T) {
+	users := echoUpstream(t, "users")
+
+	cfg := &configs.Config{
+		Gateway: &configs.Gateway{
+			Timeout: 5,
+			Routes:  []configs.Route{{Name: "users", Path: "/users", Target: mustParse(t, users.URL)}},
+		},
+	}
+
+	w, _ := doRequest(t, newHandler(cfg), httptest.NewRequest("GET", "/orders", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w, _ = doRequest(t, newHandler(&configs.Config{}), httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+}
+
+func TestGateway_Headers(t *testing.T) {
+	upstream := echoUpstream(t, "main")
+	upstreamURL := mustParse(t, upstream.URL)
+
+	cfg := &configs.Config{
+		TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+		Gateway: &configs.Gateway{
+			Upstream: upstreamURL,
+			Timeout:  5,
+			Headers:  map[string]string{"X-Gateway": "rate-limiter"},
+		},
+	}
+	handler := newHandler(cfg)
+
+	// Forwarding headers of untrusted clients are replaced
+	r := httptest.NewRequest("GET", "http://api.example.com/", nil)
+	r.RemoteAddr = "203.0.113.7:1234"
+	r.Header.Set("X-Forwarded-For", "1.2.3.4")
+	r.Header.Set("X-Forwarded-Proto", "https")
+	r.Header.Set("API_KEY", "abc")
+
+	_, body := doRequest(t, handler, r)
+	assert.Equal(t, "203.0.113.7", body.Headers.Get("X-Forwarded-For"))
+	assert.Equal(t, "http", body.Headers.Get("X-Forwarded-Proto"))
+	assert.Equal(t, "api.example.com", body.Headers.Get("X-Forwarded-Host"))
+	assert.Equal(t, "rate-limiter", body.Headers.Get("X-Gateway"))
+	assert.Equal(t, "abc", body.Headers.Get("API_KEY"))
+	assert.Equal(t, upstreamURL.Host, body.Host)
+
+	// Trusted proxies extend the chain
+	r = httptest.NewRequest("GET", "http://api.example.com/", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Set("X-Forwarded-For", "1.2.3.4")
+	r.Header.Set("X-Forwarded-Proto", "https")
+
+	_, body = doRequest(t, handler, r)
+	assert.Equal(t, "1.2.3.4, 10.0.0.1", body.Headers.Get("X-Forwarded-For"))
+	assert.Equal(t, "https", body.Headers.Get("X-Forwarded-Proto"))
+
+	cfg.Gateway.PreserveHost = true
+	_, body = doRequest(t, handler, httptest.NewRequest("GET", "http://api.example.com/", nil))
+	assert.Equal(t, "api.example.com", body.Host)
+}
+
+func TestGateway_Timeout(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer slow.Close()
+
+	cfg := &configs.Config{
+		Gateway: &configs.Gateway{
+			Upstream: mustParse(t, slow.URL),
+			Timeout:  5,
+			Routes:   []configs.Route{{Name: "slow", Path: "/slow", Timeout: 1}},
+		},
+	}
+
+	start := time.Now()
+	w, _ := doRequest(t, newHandler(cfg), httptest.NewRequest("GET", "/slow", nil))
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.JSONEq(t, `{"error":"upstream timed out"}`, w.Body.String())
+	assert.Less(t, time.Since(start), 3*time.Second)
+}
+
+func TestGateway_UpstreamUnavailable(t *testing.T) {
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	cfg := &configs.Config{
+		Gateway: &configs.Gateway{Upstream: mustParse(t, closed.URL), Timeout: 5},
+	}
+
+	w, _ := doRequest(t, newHandler(cfg), httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.JSONEq(t, `{"error":"upstream unavailable"}`, w.Body.String())
+}
+
+func TestStripSegments(t *testing.T) {
+	assert.Equal(t, "/42", stripSegments("/users/42", 1))
+	assert.Equal(t, "/", stripSegments("/users", 1))
+	assert.Equal(t, "/orders/7", stripSegments("/users/42/orders/7", 2))
+	assert.Equal(t, "/users/42", stripSegments("/users/42", 0))
+}
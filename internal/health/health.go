@@ -0,0 +1,174 @@
+// Package health serves the liveness and readiness probes of the server
+// and the detailed health report of /health.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Health statuses reported by /health
+const (
+	StatusHealthy   = "healthy"
+	StatusDegraded  = "degraded"
+	StatusUnhealthy = "unhealthy"
+)
+
+// Pinger is the part of the storage readiness depends on
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Checker answers the probes. The server is live as long as it serves
+// requests, and ready while the storage answers and no shutdown has begun.
+type Checker struct {
+	storage  Pinger
+	timeout  time.Duration
+	draining atomic.Bool
+
+	backend   string
+	fallback  bool
+	version   func() uint64
+	startedAt time.Time
+}
+
+// Option configures what the health report includes
+type Option func(*Checker)
+
+// WithBackend names the storage backend in use; fallback is set when it
+// replaces the configured one (the in-memory storage when Redis was down)
+func WithBackend(name string, fallback bool) Option {
+	return func(c *Checker) {
+		c.backend = name
+		c.fallback = fallback
+	}
+}
+
+// WithConfigVersion reports the version of the active configuration
+func WithConfigVersion(version func() uint64) Option {
+	return func(c *Checker) {
+		c.version = version
+	}
+}
+
+// NewChecker returns a checker whose readiness pings storage, giving up
+// after timeout
+func NewChecker(storage Pinger, timeout time.Duration, opts ...Option) *Checker {
+	c := &Checker{storage: storage, timeout: timeout, startedAt: time.Now()}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Report is the body of /health
+type Report struct {
+	Status        string        `json:"status"`
+	Storage       StorageReport `json:"storage"`
+	ConfigVersion uint64        `json:"config_version,omitempty"`
+	StartedAt     time.Time     `json:"started_at"`
+	UptimeSeconds int64         `json:"uptime_seconds"`
+}
+
+// StorageReport describes the storage and the outcome of its ping. The
+// error itself is logged rather than reported, since /health is public.
+type StorageReport struct {
+	Backend   string  `json:"backend,omitempty"`
+	Fallback  bool    `json:"fallback"`
+	Connected bool    `json:"connected"`
+	LatencyMS float64 `json:"latency_ms"`
+}
+
+// Drain marks the server as shutting down; from then on it is not ready,
+// so that load balancers stop sending new requests while in-flight ones
+// finish
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+// Livez always reports the server as alive
+func (c *Checker) Livez(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Readyz reports whether the server can take traffic
+func (c *Checker) Readyz(w http.ResponseWriter, r *http.Request) {
+	if c.draining.Load() {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "shutting down"})
+		return
+	}
+
+	if _, err := c.ping(r.Context()); err != nil {
+		slog.WarnContext(r.Context(), "Readiness check failed", "error", err)
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "storage unavailable"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+}
+
+// Health reports the storage and configuration in use. The server is
+// degraded, but still answers 200, while it runs on the fallback storage,
+// and unhealthy (503) when the storage does not answer.
+func (c *Checker) Health(w http.ResponseWriter, r *http.Request) {
+	report := c.Report(r.Context())
+
+	status := http.StatusOK
+	if report.Status == StatusUnhealthy {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
+
+// Report checks the storage and builds the health report
+func (c *Checker) Report(ctx context.Context) Report {
+	now := time.Now()
+	report := Report{
+		Status: StatusHealthy,
+		Storage: StorageReport{
+			Backend:  c.backend,
+			Fallback: c.fallback,
+		},
+		StartedAt:     c.startedAt.UTC(),
+		UptimeSeconds: int64(now.Sub(c.startedAt).Seconds()),
+	}
+	if c.version != nil {
+		report.ConfigVersion = c.version()
+	}
+
+	latency, err := c.ping(ctx)
+	report.Storage.LatencyMS = float64(latency.Microseconds()) / 1000
+
+	switch {
+	case err != nil:
+		slog.WarnContext(ctx, "Health check failed", "backend", c.backend, "error", err)
+		report.Status = StatusUnhealthy
+	case c.fallback:
+		report.Storage.Connected = true
+		report.Status = StatusDegraded
+	default:
+		report.Storage.Connected = true
+	}
+
+	return report
+}
+
+// ping pings the storage within the timeout of the checker
+func (c *Checker) ping(ctx context.Context) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := c.storage.Ping(ctx)
+	return time.Since(start), err
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
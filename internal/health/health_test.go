@@ -0,0 +1,129 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+type pingFunc func(ctx context.Context) error
+
+func (f pingFunc) Ping(ctx context.Context) error {
+	return f(ctx)
+}
+
+func probe(handler http.HandlerFunc, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", path, nil))
+	return w
+}
+
+func TestChecker_Livez(t *testing.T) {
+	checker := NewChecker(pingFunc(func(ctx context.Context) error {
+		return errors.New("connection refused")
+	}), time.Second)
+
+	// Liveness does not depend on the storage, nor on draining
+	w := probe(checker.Livez, "/livez")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, w.Body.String())
+
+	checker.Drain()
+	assert.Equal(t, http.StatusOK, probe(checker.Livez, "/livez").Code)
+}
+
+func TestChecker_Readyz(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	defer store.Close()
+
+	checker := NewChecker(store, time.Second)
+
+	w := probe(checker.Readyz, "/readyz")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status":"ready"}`, w.Body.String())
+
+	checker.Drain()
+	w = probe(checker.Readyz, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.JSONEq(t, `{"status":"shutting down"}`, w.Body.String())
+}
+
+func TestChecker_ReadyzStorageUnavailable(t *testing.T) {
+	checker := NewChecker(pingFunc(func(ctx context.Context) error {
+		return errors.New("connection refused")
+	}), time.Second)
+
+	w := probe(checker.Readyz, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.JSONEq(t, `{"status":"storage unavailable"}`, w.Body.String())
+}
+
+func TestChecker_ReadyzTimeout(t *testing.T) {
+	checker := NewChecker(pingFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}), 10*time.Millisecond)
+
+	assert.Equal(t, http.StatusServiceUnavailable, probe(checker.Readyz, "/readyz").Code)
+}
+
+func TestChecker_Health(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	defer store.Close()
+
+	checker := NewChecker(store, time.Second,
+		WithBackend("redis", false),
+		WithConfigVersion(func() uint64 { return 3 }),
+	)
+
+	w := probe(checker.Health, "/health")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var report Report
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, StatusHealthy, report.Status)
+	assert.Equal(t, StorageReport{Backend: "redis", Connected: true, LatencyMS: report.Storage.LatencyMS}, report.Storage)
+	assert.GreaterOrEqual(t, report.Storage.LatencyMS, 0.0)
+	assert.Equal(t, uint64(3), report.ConfigVersion)
+	assert.GreaterOrEqual(t, report.UptimeSeconds, int64(0))
+	assert.False(t, report.StartedAt.IsZero())
+}
+
+func TestChecker_HealthFallback(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	defer store.Close()
+
+	checker := NewChecker(store, time.Second, WithBackend("memory", true))
+
+	// Running on the fallback storage still serves traffic
+	w := probe(checker.Health, "/health")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var report Report
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, StatusDegraded, report.Status)
+	assert.True(t, report.Storage.Fallback)
+	assert.True(t, report.Storage.Connected)
+}
+
+func TestChecker_HealthStorageUnavailable(t *testing.T) {
+	checker := NewChecker(pingFunc(func(ctx context.Context) error {
+		return errors.New("dial tcp 10.0.0.5:6379: connection refused")
+	}), time.Second, WithBackend("redis", false))
+
+	w := probe(checker.Health, "/health")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.NotContains(t, w.Body.String(), "10.0.0.5")
+
+	var report Report
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, StatusUnhealthy, report.Status)
+	assert.False(t, report.Storage.Connected)
+}
@@ -0,0 +1,203 @@
+package jwt
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrMalformed means the token is not a well-formed compact JWS
+	ErrMalformed = errors.New("malformed token")
+
+	// ErrUnsupportedAlgorithm means the token is not signed with HS256 or RS256
+	ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+
+	// ErrInvalidSignature means no configured key verifies the token
+	ErrInvalidSignature = errors.New("invalid signature")
+
+	// ErrExpired means the token is past its exp claim or before its nbf claim
+	ErrExpired = errors.New("token expired or not yet valid")
+)
+
+// Claims are the decoded claims of a verified token
+type Claims map[string]any
+
+// String returns a claim as a string. Numeric claims are formatted without
+// exponent so that numeric ids can be used as keys.
+func (c Claims) String(name string) (string, bool) {
+	switch value := c[name].(type) {
+	case string:
+		return value, value != ""
+	case json.Number:
+		return value.String(), true
+	default:
+		return "", false
+	}
+}
+
+// Verifier checks HS256 tokens against shared secrets and RS256 tokens
+// against RSA public keys, identified by their key id
+type Verifier struct {
+	secrets [][]byte
+	keys    map[string]*rsa.PublicKey
+}
+
+func NewVerifier(secrets [][]byte, keys map[string]*rsa.PublicKey) *Verifier {
+	return &Verifier{secrets: secrets, keys: keys}
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Verify checks the signature and the exp/nbf claims of a token and
+// returns its claims
+func (v *Verifier) Verify(token string, now time.Time) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+	switch h.Alg {
+	case "HS256":
+		err = v.verifyHS256(signed, signature)
+	case "RS256":
+		err = v.verifyRS256(h.Kid, signed, signature)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, h.Alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+
+	if exp, ok := claims.time("exp"); ok && !now.Before(exp) {
+		return nil, ErrExpired
+	}
+	if nbf, ok := claims.time("nbf"); ok && now.Before(nbf) {
+		return nil, ErrExpired
+	}
+
+	return claims, nil
+}
+
+func (v *Verifier) verifyHS256(signed, signature []byte) error {
+	for _, secret := range v.secrets {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(signed)
+		if hmac.Equal(mac.Sum(nil), signature) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+func (v *Verifier) verifyRS256(kid string, signed, signature []byte) error {
+	digest := sha256.Sum256(signed)
+
+	// Without a key id every key is tried
+	for id, key := range v.keys {
+		if kid != "" && id != kid {
+			continue
+		}
+		if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+func (c Claims) time(name string) (time.Time, bool) {
+	number, ok := c[name].(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	seconds, err := number.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(seconds), 0), true
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return ErrMalformed
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		return ErrMalformed
+	}
+	return nil
+}
+
+// ParseJWKS reads the RSA keys of a JSON Web Key Set, indexed by key id.
+// Keys of other types are ignored.
+func ParseJWKS(data []byte) (map[string]*rsa.PublicKey, error) {
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for i, jwk := range set.Keys {
+		if jwk.Kty != "RSA" {
+			continue
+		}
+
+		n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+		e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+		if errN != nil || errE != nil || len(n) == 0 || len(e) == 0 {
+			return nil, fmt.Errorf("invalid JWKS: key #%d has an invalid modulus or exponent", i+1)
+		}
+
+		kid := jwk.Kid
+		if kid == "" {
+			kid = fmt.Sprintf("#%d", i+1)
+		}
+		keys[kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("invalid JWKS: no RSA keys found")
+	}
+	return keys, nil
+}
@@ -0,0 +1,117 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func encode(v any) string {
+	data, _ := json.Marshal(v)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func signHS256(secret string, claims map[string]any) string {
+	signed := encode(map[string]string{"alg": "HS256", "typ": "JWT"}) + "." + encode(claims)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func signRS256(key *rsa.PrivateKey, kid string, claims map[string]any) string {
+	signed := encode(map[string]string{"alg": "RS256", "kid": kid}) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(signed))
+	signature, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestVerify_HS256(t *testing.T) {
+	verifier := NewVerifier([][]byte{[]byte("old"), []byte("current")}, nil)
+	now := time.Now()
+
+	claims, err := verifier.Verify(signHS256("current", map[string]any{"sub": "client-1", "plan": "pro"}), now)
+	assert.NoError(t, err)
+	sub, _ := claims.String("sub")
+	assert.Equal(t, "client-1", sub)
+
+	// Rotated secrets are still accepted
+	_, err = verifier.Verify(signHS256("old", map[string]any{"sub": "client-1"}), now)
+	assert.NoError(t, err)
+
+	_, err = verifier.Verify(signHS256("wrong", map[string]any{"sub": "client-1"}), now)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	_, err = verifier.Verify(signHS256("current", map[string]any{"sub": "client-1", "exp": now.Add(-time.Minute).Unix()}), now)
+	assert.ErrorIs(t, err, ErrExpired)
+
+	_, err = verifier.Verify(signHS256("current", map[string]any{"sub": "client-1", "nbf": now.Add(time.Minute).Unix()}), now)
+	assert.ErrorIs(t, err, ErrExpired)
+}
+
+func TestVerify_RS256(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	jwks := fmt.Sprintf(`{"keys": [
+		{"kty": "EC", "kid": "ignored"},
+		{"kty": "RSA", "kid": "k1", "n": %q, "e": %q}
+	]}`,
+		base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()))
+
+	keys, err := ParseJWKS([]byte(jwks))
+	assert.NoError(t, err)
+	assert.Len(t, keys, 1)
+
+	verifier := NewVerifier(nil, keys)
+	now := time.Now()
+
+	claims, err := verifier.Verify(signRS256(key, "k1", map[string]any{"client_id": 42}), now)
+	assert.NoError(t, err)
+	clientID, ok := claims.String("client_id")
+	assert.True(t, ok)
+	assert.Equal(t, "42", clientID)
+
+	_, err = verifier.Verify(signRS256(key, "unknown", map[string]any{"sub": "x"}), now)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	// An HS256 token signed with the public modulus must not be accepted
+	_, err = verifier.Verify(signHS256(string(key.N.Bytes()), map[string]any{"sub": "x"}), now)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+func TestVerify_Malformed(t *testing.T) {
+	verifier := NewVerifier([][]byte{[]byte("secret")}, nil)
+	now := time.Now()
+
+	_, err := verifier.Verify("not-a-token", now)
+	assert.ErrorIs(t, err, ErrMalformed)
+
+	_, err = verifier.Verify("!!.!!.!!", now)
+	assert.ErrorIs(t, err, ErrMalformed)
+
+	none := encode(map[string]string{"alg": "none"}) + "." + encode(map[string]any{"sub": "x"}) + "."
+	_, err = verifier.Verify(none, now)
+	assert.ErrorIs(t, err, ErrUnsupportedAlgorithm)
+}
+
+func TestParseJWKS_Invalid(t *testing.T) {
+	_, err := ParseJWKS([]byte(`not json`))
+	assert.Error(t, err)
+
+	_, err = ParseJWKS([]byte(`{"keys": [{"kty": "EC"}]}`))
+	assert.EqualError(t, err, "invalid JWKS: no RSA keys found")
+
+	_, err = ParseJWKS([]byte(`{"keys": [{"kty": "RSA", "n": "", "e": "AQAB"}]}`))
+	assert.EqualError(t, err, "invalid JWKS: key #1 has an invalid modulus or exponent")
+}
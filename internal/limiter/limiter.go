@@ -3,6 +3,7 @@ package limiter
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/storage"
@@ -10,48 +11,316 @@ import (
 
 type RateLimiter struct {
 	storage storage.Storage
+
+	overrides map[string]Override
+	mu        sync.RWMutex
+}
+
+// Override temporarily replaces the limit and block duration of a key
+type Override struct {
+	Limit         int
+	BlockDuration time.Duration
+	ExpiresAt     time.Time
 }
 
 func NewRateLimiter(storage storage.Storage) *RateLimiter {
 	return &RateLimiter{
-		storage: storage,
+		storage:   storage,
+		overrides: make(map[string]Override),
 	}
 }
 
-// AllowRequest checks if a request should be allowed
+// Limit describes how many requests a key may make within a window and for
+// how long it is blocked once it goes over
+type Limit struct {
+	Requests      int
+	Window        time.Duration
+	BlockDuration time.Duration
+
+	// Quota, when positive, also caps the requests allowed over the longer
+	// QuotaWindow. Going over the quota does not block the key: requests
+	// are rejected until the quota window resets.
+	Quota       int
+	QuotaWindow time.Duration
+
+	// Rule and KeyType describe where the limit comes from; they are only
+	// copied into the Decision
+	Rule    string
+	KeyType string
+
+	// Identity is the key runtime overrides are looked up under, for
+	// limiter keys that scope an identity to a rule (e.g. "token:<hash>"
+	// for "rule:api:token:<hash>"). Empty means the limiter key itself.
+	Identity string
+}
+
+// Reason explains a Decision
+type Reason string
+
+const (
+	// ReasonAllowed means the request is within the limit
+	ReasonAllowed Reason = "allowed"
+
+	// ReasonBlocked means the key was already blocked by a previous overage
+	ReasonBlocked Reason = "blocked"
+
+	// ReasonExceeded means this request went over the limit and blocked the key
+	ReasonExceeded Reason = "exceeded"
+
+	// ReasonQuotaExceeded means the quota of the key is used up
+	ReasonQuotaExceeded Reason = "quota_exceeded"
+)
+
+// Decision is the outcome of evaluating a request against a limit
+type Decision struct {
+	Allowed bool
+	Reason  Reason
+
+	// Key is the limiter key, Rule the name of the applied rule or policy
+	// and KeyType what the key identifies (ip, token...)
+	Key     string
+	Rule    string
+	KeyType string
+
+	// Count is the number of requests seen in the current window; it is not
+	// known (0) when the key was already blocked
+	Count int64
+
+	// Limit and Window describe the limit that was applied
+	Limit  int
+	Window time.Duration
+
+	// Remaining is how many more requests are allowed in the current window
+	Remaining int
+
+	// ResetAt is when the current window ends or, for a blocked key, when
+	// the block expires
+	ResetAt time.Time
+
+	// RetryAfter is how long a rejected client must wait before retrying
+	RetryAfter time.Duration
+}
+
+// AllowRequest checks if a request should be allowed, using a 1-second window.
+// It is a thin wrapper around Allow for callers that only need a yes or no.
 func (rl *RateLimiter) AllowRequest(ctx context.Context, key string, limit int, blockDuration time.Duration) (bool, error) {
+	decision, err := rl.Allow(ctx, key, Limit{
+		Requests:      limit,
+		Window:        1 * time.Second,
+		BlockDuration: blockDuration,
+	})
+	return decision.Allowed, err
+}
+
+// Allow checks if a request should be allowed under the given limit
+func (rl *RateLimiter) Allow(ctx context.Context, key string, limit Limit) (Decision, error) {
+	// Runtime overrides take precedence over the configured limits
+	overrideKey := key
+	if limit.Identity != "" {
+		overrideKey = limit.Identity
+	}
+	if override, ok := rl.getOverride(overrideKey); ok {
+		limit.Requests = override.Limit
+		limit.BlockDuration = override.BlockDuration
+	}
+
+	now := time.Now()
+	decision := Decision{
+		Key:     key,
+		Rule:    limit.Rule,
+		KeyType: limit.KeyType,
+		Limit:   limit.Requests,
+		Window:  limit.Window,
+	}
+
 	// Check if already blocked
-	blocked, err := rl.storage.IsBlocked(ctx, key)
+	blockTTL, err := rl.storage.BlockTTL(ctx, key)
 	if err != nil {
-		return false, fmt.Errorf("failed to check block status: %w", err)
+		return decision, fmt.Errorf("failed to check block status: %w", err)
+	}
+
+	if blockTTL > 0 {
+		decision.Reason = ReasonBlocked
+		decision.ResetAt = now.Add(blockTTL)
+		decision.RetryAfter = blockTTL
+		return decision, nil
 	}
 
-	if blocked {
-		return false, nil
+	// A used-up quota rejects the request without counting it
+	quotaKey := key + ":quota"
+	if limit.Quota > 0 {
+		used, err := rl.storage.Get(ctx, quotaKey)
+		if err != nil {
+			return decision, fmt.Errorf("failed to get quota: %w", err)
+		}
+		if used >= int64(limit.Quota) {
+			return rl.quotaExceeded(ctx, decision, quotaKey, now)
+		}
 	}
 
-	// Increment counter with 1-second window
-	count, err := rl.storage.Increment(ctx, key, 1*time.Second)
+	// Increment counter for the current window
+	count, err := rl.storage.Increment(ctx, key, limit.Window)
 	if err != nil {
-		return false, fmt.Errorf("failed to increment counter: %w", err)
+		return decision, fmt.Errorf("failed to increment counter: %w", err)
 	}
 
+	decision.Count = count
+
 	// Check if limit exceeded
-	if count > int64(limit) {
+	if count > int64(limit.Requests) {
 		// Block the key
-		if err := rl.storage.SetBlock(ctx, key, blockDuration); err != nil {
-			return false, fmt.Errorf("failed to set block: %w", err)
+		if err := rl.storage.SetBlock(ctx, key, limit.BlockDuration); err != nil {
+			return decision, fmt.Errorf("failed to set block: %w", err)
+		}
+		decision.Reason = ReasonExceeded
+		decision.ResetAt = now.Add(limit.BlockDuration)
+		decision.RetryAfter = limit.BlockDuration
+		return decision, nil
+	}
+
+	windowTTL, err := rl.storage.TTL(ctx, key)
+	if err != nil {
+		return decision, fmt.Errorf("failed to get counter ttl: %w", err)
+	}
+
+	decision.Allowed = true
+	decision.Reason = ReasonAllowed
+	decision.Remaining = limit.Requests - int(count)
+	decision.ResetAt = now.Add(windowTTL)
+
+	if limit.Quota > 0 {
+		used, err := rl.storage.Increment(ctx, quotaKey, limit.QuotaWindow)
+		if err != nil {
+			return decision, fmt.Errorf("failed to increment quota: %w", err)
 		}
-		return false, nil
+		if used > int64(limit.Quota) {
+			decision.Allowed = false
+			return rl.quotaExceeded(ctx, decision, quotaKey, now)
+		}
+		decision.Remaining = min(decision.Remaining, limit.Quota-int(used))
 	}
 
-	return true, nil
+	return decision, nil
+}
+
+func (rl *RateLimiter) quotaExceeded(ctx context.Context, decision Decision, quotaKey string, now time.Time) (Decision, error) {
+	quotaTTL, err := rl.storage.TTL(ctx, quotaKey)
+	if err != nil {
+		return decision, fmt.Errorf("failed to get quota ttl: %w", err)
+	}
+
+	decision.Reason = ReasonQuotaExceeded
+	decision.Remaining = 0
+	decision.ResetAt = now.Add(quotaTTL)
+	decision.RetryAfter = quotaTTL
+	return decision, nil
 }
 
 func (rl *RateLimiter) IsBlocked(ctx context.Context, key string) (bool, error) {
 	return rl.storage.IsBlocked(ctx, key)
 }
 
+// BlockTTL returns how long a key stays blocked, 0 when it is not
+func (rl *RateLimiter) BlockTTL(ctx context.Context, key string) (time.Duration, error) {
+	return rl.storage.BlockTTL(ctx, key)
+}
+
 func (rl *RateLimiter) GetCurrentCount(ctx context.Context, key string) (int64, error) {
 	return rl.storage.Get(ctx, key)
 }
+
+// ListBlocks returns all keys that are currently blocked
+func (rl *RateLimiter) ListBlocks(ctx context.Context) ([]storage.Block, error) {
+	return rl.storage.ListBlocks(ctx)
+}
+
+// ListCounters returns all counters that have not expired yet
+func (rl *RateLimiter) ListCounters(ctx context.Context) ([]storage.Counter, error) {
+	return rl.storage.ListCounters(ctx)
+}
+
+// Unblock lifts the block of a key without touching its counter
+func (rl *RateLimiter) Unblock(ctx context.Context, key string) error {
+	return rl.storage.Unblock(ctx, key)
+}
+
+// Reset clears both the counter and the block of a key
+func (rl *RateLimiter) Reset(ctx context.Context, key string) error {
+	if err := rl.storage.Unblock(ctx, key); err != nil {
+		return err
+	}
+	return rl.storage.Reset(ctx, key)
+}
+
+// LookupToken returns the record of a token registered in storage
+func (rl *RateLimiter) LookupToken(ctx context.Context, hash string) (storage.TokenRecord, bool, error) {
+	return rl.storage.GetToken(ctx, hash)
+}
+
+// RegisterToken registers a token in storage by hash
+func (rl *RateLimiter) RegisterToken(ctx context.Context, hash string, record storage.TokenRecord) error {
+	return rl.storage.SetToken(ctx, hash, record)
+}
+
+// RemoveToken removes a token from the storage registry
+func (rl *RateLimiter) RemoveToken(ctx context.Context, hash string) error {
+	return rl.storage.DeleteToken(ctx, hash)
+}
+
+// ListTokens returns every token registered in storage, indexed by hash
+func (rl *RateLimiter) ListTokens(ctx context.Context) (map[string]storage.TokenRecord, error) {
+	return rl.storage.ListTokens(ctx)
+}
+
+// SetOverride replaces the limits of a key until ttl elapses
+func (rl *RateLimiter) SetOverride(key string, limit int, blockDuration, ttl time.Duration) Override {
+	override := Override{
+		Limit:         limit,
+		BlockDuration: blockDuration,
+		ExpiresAt:     time.Now().Add(ttl),
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.overrides[key] = override
+	return override
+}
+
+// RemoveOverride restores the configured limits of a key
+func (rl *RateLimiter) RemoveOverride(key string) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	delete(rl.overrides, key)
+}
+
+// Overrides returns all overrides that have not expired yet
+func (rl *RateLimiter) Overrides() map[string]Override {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	overrides := make(map[string]Override, len(rl.overrides))
+	for key, override := range rl.overrides {
+		if now.After(override.ExpiresAt) {
+			delete(rl.overrides, key)
+			continue
+		}
+		overrides[key] = override
+	}
+
+	return overrides
+}
+
+func (rl *RateLimiter) getOverride(key string) (Override, bool) {
+	rl.mu.RLock()
+	override, exists := rl.overrides[key]
+	rl.mu.RUnlock()
+
+	if !exists || time.Now().After(override.ExpiresAt) {
+		return Override{}, false
+	}
+
+	return override, true
+}
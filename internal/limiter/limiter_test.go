@@ -120,3 +120,144 @@ func TestRateLimiter_WindowReset(t *testing.T) {
 	assert.NoError(t, err)
 	assert.True(t, allowed)
 }
+
+func TestRateLimiter_Override(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	limiter := NewRateLimiter(store)
+	ctx := context.Background()
+
+	limiter.SetOverride("test-key", 2, 1*time.Second, 1*time.Minute)
+	assert.Len(t, limiter.Overrides(), 1)
+
+	for i := 0; i < 2; i++ {
+		allowed, err := limiter.AllowRequest(ctx, "test-key", 10, 1*time.Second)
+		assert.NoError(t, err)
+		assert.True(t, allowed)
+	}
+
+	// Override limit is 2, configured limit of 10 is ignored
+	allowed, err := limiter.AllowRequest(ctx, "test-key", 10, 1*time.Second)
+	assert.NoError(t, err)
+	assert.False(t, allowed)
+
+	limiter.RemoveOverride("test-key")
+	assert.Empty(t, limiter.Overrides())
+}
+
+func TestRateLimiter_OverrideByIdentity(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	limiter := NewRateLimiter(store)
+	ctx := context.Background()
+
+	limiter.SetOverride("token:abc", 1, 1*time.Second, 1*time.Minute)
+
+	// The override of the identity applies to a rule key scoped to it
+	limit := Limit{Requests: 10, Window: 1 * time.Second, BlockDuration: 1 * time.Second, Identity: "token:abc"}
+	decision, err := limiter.Allow(ctx, "rule:api:token:abc", limit)
+	assert.NoError(t, err)
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, 1, decision.Limit)
+
+	decision, err = limiter.Allow(ctx, "rule:api:token:abc", limit)
+	assert.NoError(t, err)
+	assert.False(t, decision.Allowed)
+}
+
+func TestRateLimiter_Reset(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	limiter := NewRateLimiter(store)
+	ctx := context.Background()
+
+	for i := 0; i <= 5; i++ {
+		limiter.AllowRequest(ctx, "test-key", 5, 1*time.Minute)
+	}
+
+	blocked, err := limiter.IsBlocked(ctx, "test-key")
+	assert.NoError(t, err)
+	assert.True(t, blocked)
+
+	err = limiter.Reset(ctx, "test-key")
+	assert.NoError(t, err)
+
+	allowed, err := limiter.AllowRequest(ctx, "test-key", 5, 1*time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, allowed)
+}
+
+func TestRateLimiter_AllowDecision(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	limiter := NewRateLimiter(store)
+	ctx := context.Background()
+	limit := Limit{Requests: 2, Window: 10 * time.Second, BlockDuration: 1 * time.Minute, Rule: "api", KeyType: "ip"}
+
+	decision, err := limiter.Allow(ctx, "test-key", limit)
+	assert.NoError(t, err)
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, ReasonAllowed, decision.Reason)
+	assert.Equal(t, "test-key", decision.Key)
+	assert.Equal(t, "api", decision.Rule)
+	assert.Equal(t, "ip", decision.KeyType)
+	assert.Equal(t, int64(1), decision.Count)
+	assert.Equal(t, 2, decision.Limit)
+	assert.Equal(t, 1, decision.Remaining)
+	assert.WithinDuration(t, time.Now().Add(10*time.Second), decision.ResetAt, 100*time.Millisecond)
+
+	decision, err = limiter.Allow(ctx, "test-key", limit)
+	assert.NoError(t, err)
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, 0, decision.Remaining)
+
+	// Exceeding the limit blocks the key for the block duration
+	decision, err = limiter.Allow(ctx, "test-key", limit)
+	assert.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, ReasonExceeded, decision.Reason)
+	assert.Equal(t, int64(3), decision.Count)
+	assert.Equal(t, 1*time.Minute, decision.RetryAfter)
+	assert.WithinDuration(t, time.Now().Add(1*time.Minute), decision.ResetAt, 100*time.Millisecond)
+
+	// While blocked, the retry delay comes from the remaining block time
+	decision, err = limiter.Allow(ctx, "test-key", limit)
+	assert.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, ReasonBlocked, decision.Reason)
+	assert.InDelta(t, float64(1*time.Minute), float64(decision.RetryAfter), float64(100*time.Millisecond))
+}
+
+func TestRateLimiter_Quota(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	limiter := NewRateLimiter(store)
+	ctx := context.Background()
+	limit := Limit{
+		Requests:      2,
+		Window:        100 * time.Millisecond,
+		BlockDuration: 1 * time.Minute,
+		Quota:         3,
+		QuotaWindow:   1 * time.Hour,
+	}
+
+	decision, err := limiter.Allow(ctx, "test-key", limit)
+	assert.NoError(t, err)
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, 1, decision.Remaining)
+
+	decision, _ = limiter.Allow(ctx, "test-key", limit)
+	assert.True(t, decision.Allowed)
+
+	// A new window resets the counter but not the quota
+	time.Sleep(150 * time.Millisecond)
+
+	decision, _ = limiter.Allow(ctx, "test-key", limit)
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, 0, decision.Remaining)
+
+	decision, err = limiter.Allow(ctx, "test-key", limit)
+	assert.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, ReasonQuotaExceeded, decision.Reason)
+	assert.InDelta(t, time.Hour.Seconds(), decision.RetryAfter.Seconds(), 1)
+
+	// Going over the quota does not block the key
+	blocked, _ := limiter.IsBlocked(ctx, "test-key")
+	assert.False(t, blocked)
+}
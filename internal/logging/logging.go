@@ -0,0 +1,26 @@
+// Package logging builds the structured logger of the server
+package logging
+
+import (
+	"io"
+	"log/slog"
+
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/configs"
+)
+
+// New returns a logger writing to w in the given format (configs.LogFormatJSON
+// or configs.LogFormatText) at the given level ("debug", "info", "warn" or
+// "error"). Unknown values fall back to text and info; LoadConfig rejects
+// them anyway.
+func New(w io.Writer, format, level string) *slog.Logger {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		lvl = slog.LevelInfo
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	if format == configs.LogFormatJSON {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
@@ -0,0 +1,38 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/configs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, configs.LogFormatJSON, "warn")
+
+	logger.Info("hidden")
+	logger.Warn("shown", "rule", "login")
+
+	var entry map[string]any
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "WARN", entry["level"])
+	assert.Equal(t, "shown", entry["msg"])
+	assert.Equal(t, "login", entry["rule"])
+}
+
+func TestNew_Text(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, configs.LogFormatText, "debug")
+
+	logger.Debug("details", "count", 3)
+	assert.Contains(t, buf.String(), "level=DEBUG msg=details count=3")
+
+	// Unknown levels fall back to info
+	buf.Reset()
+	logger = New(&buf, configs.LogFormatText, "verbose")
+	logger.Debug("hidden")
+	assert.Empty(t, buf.String())
+}
@@ -0,0 +1,126 @@
+// Package metrics exposes the state of the rate limiter in the Prometheus
+// text format. Labels only carry bounded values (key types, rule names,
+// storage operations); raw IPs, tokens and keys are never used as labels.
+package metrics
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "ratelimiter"
+
+// Decision results
+const (
+	ResultAllowed        = "allowed"
+	ResultRejected       = "rejected"
+	ResultShadowRejected = "shadow_rejected"
+	ResultError          = "error"
+)
+
+// Metrics holds the collectors of the rate limiter
+type Metrics struct {
+	registry *prometheus.Registry
+
+	decisions      *prometheus.CounterVec
+	storageLatency *prometheus.HistogramVec
+	storageErrors  *prometheus.CounterVec
+	fallback       prometheus.Gauge
+}
+
+// New creates the collectors, along with the Go runtime and process ones,
+// in a registry of their own
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+
+		decisions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "decisions_total",
+			Help:      "Rate limit decisions by result, key type and rule.",
+		}, []string{"result", "key_type", "rule"}),
+
+		storageLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "storage_operation_duration_seconds",
+			Help:      "Latency of storage operations.",
+			Buckets:   []float64{.0001, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"operation"}),
+
+		storageErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "storage_errors_total",
+			Help:      "Failed storage operations.",
+		}, []string{"operation"}),
+
+		fallback: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "storage_fallback",
+			Help:      "1 when the in-memory storage is used because Redis was unavailable.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		m.decisions,
+		m.storageLatency,
+		m.storageErrors,
+		m.fallback,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveDecision counts a decision
+func (m *Metrics) ObserveDecision(result, keyType, rule string) {
+	m.decisions.WithLabelValues(result, keyType, rule).Inc()
+}
+
+// ObserveStorage records the latency and outcome of a storage operation
+func (m *Metrics) ObserveStorage(operation string, duration time.Duration, err error) {
+	m.storageLatency.WithLabelValues(operation).Observe(duration.Seconds())
+	if err != nil {
+		m.storageErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+// SetFallback reports whether the in-memory fallback storage is in use
+func (m *Metrics) SetFallback(active bool) {
+	if active {
+		m.fallback.Set(1)
+	} else {
+		m.fallback.Set(0)
+	}
+}
+
+// RegisterActiveBlocks adds a gauge of the active blocks, counted by calling
+// count on every scrape
+func (m *Metrics) RegisterActiveBlocks(count func(ctx context.Context) (int, error)) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_blocks",
+		Help:      "Keys currently blocked.",
+	}, func() float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		// An unknown count is reported as NaN rather than as zero blocks
+		n, err := count(ctx)
+		if err != nil {
+			return math.NaN()
+		}
+		return float64(n)
+	}))
+}
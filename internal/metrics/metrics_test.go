@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := io.ReadAll(w.Body)
+	assert.NoError(t, err)
+	return string(body)
+}
+
+func TestMetrics_Decisions(t *testing.T) {
+	m := New()
+	m.ObserveDecision(ResultAllowed, "ip", "default")
+	m.ObserveDecision(ResultAllowed, "ip", "default")
+	m.ObserveDecision(ResultRejected, "token", "login")
+
+	body := scrape(t, m)
+	assert.Contains(t, body, `ratelimiter_decisions_total{key_type="ip",result="allowed",rule="default"} 2`)
+	assert.Contains(t, body, `ratelimiter_decisions_total{key_type="token",result="rejected",rule="login"} 1`)
+	assert.Contains(t, body, "go_goroutines")
+}
+
+func TestMetrics_FallbackAndBlocks(t *testing.T) {
+	m := New()
+	m.RegisterActiveBlocks(func(ctx context.Context) (int, error) { return 3, nil })
+
+	body := scrape(t, m)
+	assert.Contains(t, body, "ratelimiter_storage_fallback 0")
+	assert.Contains(t, body, "ratelimiter_active_blocks 3")
+
+	m.SetFallback(true)
+	assert.Contains(t, scrape(t, m), "ratelimiter_storage_fallback 1")
+
+	m = New()
+	m.RegisterActiveBlocks(func(ctx context.Context) (int, error) { return 0, errors.New("unreachable") })
+	assert.Contains(t, scrape(t, m), "ratelimiter_active_blocks NaN")
+}
+
+func TestInstrumentStorage(t *testing.T) {
+	m := New()
+	store := InstrumentStorage(storage.NewMemoryStorage(), m)
+	ctx := context.Background()
+
+	_, err := store.Increment(ctx, "ip:10.0.0.1", time.Second)
+	assert.NoError(t, err)
+	_, err = store.IsBlocked(ctx, "ip:10.0.0.1")
+	assert.NoError(t, err)
+
+	body := scrape(t, m)
+	assert.Contains(t, body, `ratelimiter_storage_operation_duration_seconds_count{operation="increment"} 1`)
+	assert.Contains(t, body, `ratelimiter_storage_operation_duration_seconds_count{operation="is_blocked"} 1`)
+
+	// Keys never become labels
+	assert.NotContains(t, body, "10.0.0.1")
+}
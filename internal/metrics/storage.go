@@ -0,0 +1,125 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/storage"
+)
+
+// instrumentedStorage records the latency of every operation of a storage
+type instrumentedStorage struct {
+	storage.Storage
+	metrics *Metrics
+}
+
+// InstrumentStorage wraps s so that the latency and errors of its
+// operations are recorded in m
+func InstrumentStorage(s storage.Storage, m *Metrics) storage.Storage {
+	return &instrumentedStorage{Storage: s, metrics: m}
+}
+
+func (s *instrumentedStorage) Increment(ctx context.Context, key string, expiration time.Duration) (int64, error) {
+	start := time.Now()
+	result, err := s.Storage.Increment(ctx, key, expiration)
+	s.metrics.ObserveStorage("increment", time.Since(start), err)
+	return result, err
+}
+
+func (s *instrumentedStorage) Get(ctx context.Context, key string) (int64, error) {
+	start := time.Now()
+	result, err := s.Storage.Get(ctx, key)
+	s.metrics.ObserveStorage("get", time.Since(start), err)
+	return result, err
+}
+
+func (s *instrumentedStorage) TTL(ctx context.Context, key string) (time.Duration, error) {
+	start := time.Now()
+	result, err := s.Storage.TTL(ctx, key)
+	s.metrics.ObserveStorage("ttl", time.Since(start), err)
+	return result, err
+}
+
+func (s *instrumentedStorage) Reset(ctx context.Context, key string) error {
+	start := time.Now()
+	err := s.Storage.Reset(ctx, key)
+	s.metrics.ObserveStorage("reset", time.Since(start), err)
+	return err
+}
+
+func (s *instrumentedStorage) SetBlock(ctx context.Context, key string, duration time.Duration) error {
+	start := time.Now()
+	err := s.Storage.SetBlock(ctx, key, duration)
+	s.metrics.ObserveStorage("set_block", time.Since(start), err)
+	return err
+}
+
+func (s *instrumentedStorage) IsBlocked(ctx context.Context, key string) (bool, error) {
+	start := time.Now()
+	result, err := s.Storage.IsBlocked(ctx, key)
+	s.metrics.ObserveStorage("is_blocked", time.Since(start), err)
+	return result, err
+}
+
+func (s *instrumentedStorage) BlockTTL(ctx context.Context, key string) (time.Duration, error) {
+	start := time.Now()
+	result, err := s.Storage.BlockTTL(ctx, key)
+	s.metrics.ObserveStorage("block_ttl", time.Since(start), err)
+	return result, err
+}
+
+func (s *instrumentedStorage) Unblock(ctx context.Context, key string) error {
+	start := time.Now()
+	err := s.Storage.Unblock(ctx, key)
+	s.metrics.ObserveStorage("unblock", time.Since(start), err)
+	return err
+}
+
+func (s *instrumentedStorage) ListBlocks(ctx context.Context) ([]storage.Block, error) {
+	start := time.Now()
+	result, err := s.Storage.ListBlocks(ctx)
+	s.metrics.ObserveStorage("list_blocks", time.Since(start), err)
+	return result, err
+}
+
+func (s *instrumentedStorage) ListCounters(ctx context.Context) ([]storage.Counter, error) {
+	start := time.Now()
+	result, err := s.Storage.ListCounters(ctx)
+	s.metrics.ObserveStorage("list_counters", time.Since(start), err)
+	return result, err
+}
+
+func (s *instrumentedStorage) GetToken(ctx context.Context, hash string) (storage.TokenRecord, bool, error) {
+	start := time.Now()
+	record, ok, err := s.Storage.GetToken(ctx, hash)
+	s.metrics.ObserveStorage("get_token", time.Since(start), err)
+	return record, ok, err
+}
+
+func (s *instrumentedStorage) SetToken(ctx context.Context, hash string, record storage.TokenRecord) error {
+	start := time.Now()
+	err := s.Storage.SetToken(ctx, hash, record)
+	s.metrics.ObserveStorage("set_token", time.Since(start), err)
+	return err
+}
+
+func (s *instrumentedStorage) DeleteToken(ctx context.Context, hash string) error {
+	start := time.Now()
+	err := s.Storage.DeleteToken(ctx, hash)
+	s.metrics.ObserveStorage("delete_token", time.Since(start), err)
+	return err
+}
+
+func (s *instrumentedStorage) ListTokens(ctx context.Context) (map[string]storage.TokenRecord, error) {
+	start := time.Now()
+	result, err := s.Storage.ListTokens(ctx)
+	s.metrics.ObserveStorage("list_tokens", time.Since(start), err)
+	return result, err
+}
+
+func (s *instrumentedStorage) Ping(ctx context.Context) error {
+	start := time.Now()
+	err := s.Storage.Ping(ctx)
+	s.metrics.ObserveStorage("ping", time.Since(start), err)
+	return err
+}
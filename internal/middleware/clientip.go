@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/configs"
+)
+
+// clientSubnet returns the client address aggregated to the configured
+// IPv4/IPv6 prefix, which is what IP-based limits are keyed on
+func clientSubnet(cfg *configs.Config, r *http.Request) string {
+	ip := clientIP(cfg, r)
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ip
+	}
+	return cfg.AggregateIP(addr)
+}
+
+// clientIP returns the address of the client that sent the request.
+//
+// Forwarding headers can be set by anyone, so they are only believed when
+// the connection comes from a trusted proxy. The hops listed in the header
+// are then walked from right (closest to us) to left, and the first one
+// that is not a trusted proxy is the client.
+func clientIP(cfg *configs.Config, r *http.Request) string {
+	remote, ok := RemoteAddr(r)
+	if !ok {
+		return r.RemoteAddr
+	}
+
+	source := cfg.ClientIPSource
+	if source == configs.ClientIPRemoteAddr || !cfg.IsTrustedProxy(remote) {
+		return remote.String()
+	}
+
+	hops := forwardedHops(r, source)
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := parseHop(hops[i])
+		if err != nil {
+			// Unparsable or obfuscated hop: nothing further left can be
+			// trusted, so the last valid hop is the client
+			break
+		}
+
+		client = hop
+		if !cfg.IsTrustedProxy(hop) {
+			break
+		}
+	}
+
+	return client.String()
+}
+
+// forwardedHops returns the forwarding chain reported by the configured
+// header, ordered from the original client to the last proxy
+func forwardedHops(r *http.Request, source string) []string {
+	switch source {
+	case configs.ClientIPForwarded:
+		return forwardedFor(r.Header.Values("Forwarded"))
+	case configs.ClientIPXForwardedFor:
+		return splitHeader(r.Header.Values("X-Forwarded-For"))
+	case configs.ClientIPXRealIP:
+		return splitHeader(r.Header.Values("X-Real-IP"))
+	}
+
+	// Auto: use the most standard header present
+	if values := r.Header.Values("Forwarded"); len(values) > 0 {
+		return forwardedFor(values)
+	}
+	if values := r.Header.Values("X-Forwarded-For"); len(values) > 0 {
+		return splitHeader(values)
+	}
+	return splitHeader(r.Header.Values("X-Real-IP"))
+}
+
+// forwardedFor extracts the "for" parameter of every element of RFC 7239
+// Forwarded headers, e.g. `for=192.0.2.60;proto=http, for="[2001:db8::1]:4711"`
+func forwardedFor(values []string) []string {
+	var hops []string
+	for _, element := range splitHeader(values) {
+		hop := "unknown"
+		for _, pair := range strings.Split(element, ";") {
+			name, value, found := strings.Cut(strings.TrimSpace(pair), "=")
+			if found && strings.EqualFold(name, "for") {
+				hop = strings.Trim(value, `"`)
+			}
+		}
+		hops = append(hops, hop)
+	}
+	return hops
+}
+
+func splitHeader(values []string) []string {
+	var parts []string
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				parts = append(parts, part)
+			}
+		}
+	}
+	return parts
+}
+
+// parseHop parses an address that may carry a port or IPv6 brackets
+func parseHop(hop string) (netip.Addr, error) {
+	if addrPort, err := netip.ParseAddrPort(hop); err == nil {
+		return addrPort.Addr().Unmap(), nil
+	}
+
+	addr, err := netip.ParseAddr(strings.Trim(hop, "[]"))
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	return addr.Unmap(), nil
+}
+
+// RemoteAddr returns the address of the peer a request comes from, which
+// is the client unless it is a trusted proxy
+func RemoteAddr(r *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
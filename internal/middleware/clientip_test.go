@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/configs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("2001:db8:ffff::/48"),
+	}
+
+	tests := []struct {
+		name       string
+		source     string
+		trusted    []netip.Prefix
+		remoteAddr string
+		headers    map[string]string
+		expected   string
+	}{
+		{
+			name:       "no trusted proxies ignores headers",
+			remoteAddr: "203.0.113.7:1234",
+			headers:    map[string]string{"X-Forwarded-For": "1.2.3.4", "X-Real-IP": "5.6.7.8"},
+			expected:   "203.0.113.7",
+		},
+		{
+			name:       "untrusted peer cannot spoof",
+			trusted:    trusted,
+			remoteAddr: "203.0.113.7:1234",
+			headers:    map[string]string{"X-Forwarded-For": "1.2.3.4"},
+			expected:   "203.0.113.7",
+		},
+		{
+			name:       "trusted proxy reports client",
+			trusted:    trusted,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.9"},
+			expected:   "198.51.100.9",
+		},
+		{
+			name:       "spoofed entries left of the first untrusted hop are ignored",
+			trusted:    trusted,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string]string{"X-Forwarded-For": "1.2.3.4, 198.51.100.9, 10.0.0.2"},
+			expected:   "198.51.100.9",
+		},
+		{
+			name:       "all hops trusted returns the leftmost",
+			trusted:    trusted,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"},
+			expected:   "10.0.0.3",
+		},
+		{
+			name:       "invalid hop stops the walk",
+			trusted:    trusted,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string]string{"X-Forwarded-For": "1.2.3.4, garbage, 10.0.0.2"},
+			expected:   "10.0.0.2",
+		},
+		{
+			name:       "forwarded header takes precedence in auto mode",
+			trusted:    trusted,
+			remoteAddr: "10.0.0.1:1234",
+			headers: map[string]string{
+				"Forwarded":       `for=192.0.2.60;proto=http, for="[2001:db8::1]:4711"`,
+				"X-Forwarded-For": "1.2.3.4",
+			},
+			expected: "2001:db8::1",
+		},
+		{
+			name:       "forwarded header with obfuscated hop",
+			source:     configs.ClientIPForwarded,
+			trusted:    trusted,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string]string{"Forwarded": `for=_hidden, for=192.0.2.60`},
+			expected:   "192.0.2.60",
+		},
+		{
+			name:       "x-real-ip through trusted proxy",
+			source:     configs.ClientIPXRealIP,
+			trusted:    trusted,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string]string{"X-Forwarded-For": "1.2.3.4", "X-Real-IP": "198.51.100.9"},
+			expected:   "198.51.100.9",
+		},
+		{
+			name:       "remote-addr mode ignores headers",
+			source:     configs.ClientIPRemoteAddr,
+			trusted:    trusted,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.9"},
+			expected:   "10.0.0.1",
+		},
+		{
+			name:       "ipv6 trusted proxy",
+			trusted:    trusted,
+			remoteAddr: "[2001:db8:ffff::1]:443",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.9"},
+			expected:   "198.51.100.9",
+		},
+		{
+			name:       "ipv4-mapped remote address",
+			trusted:    trusted,
+			remoteAddr: "[::ffff:10.0.0.1]:443",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.9"},
+			expected:   "198.51.100.9",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &configs.Config{ClientIPSource: tt.source, TrustedProxies: tt.trusted}
+			if cfg.ClientIPSource == "" {
+				cfg.ClientIPSource = configs.ClientIPAuto
+			}
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+
+			assert.Equal(t, tt.expected, clientIP(cfg, req))
+		})
+	}
+}
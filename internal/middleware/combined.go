@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/configs"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/limiter"
+)
+
+// combinedTargets returns the limits enforced along with the limit of a
+// token, as configured by cfg.CombinedLimits. Requests limited by anything
+// else have no combined limits.
+func combinedTargets(cfg *configs.Config, r *http.Request, primary target) []target {
+	if primary.limit.KeyType != KeyTypeToken || len(cfg.CombinedLimits) == 0 {
+		return nil
+	}
+
+	var targets []target
+	if cfg.CombinedLimits[configs.CombinedIP] {
+		targets = append(targets, ipTarget(cfg, r))
+	}
+	if cfg.CombinedLimits[configs.CombinedTokenIP] {
+		hash := configs.HashToken(r.Header.Get("API_KEY"))
+		targets = append(targets, target{
+			key: fmt.Sprintf("token-ip:%s:%s", hash, clientSubnet(cfg, r)),
+			limit: limiter.Limit{
+				Requests:      cfg.RateLimitTokenIP,
+				Window:        1 * time.Second,
+				BlockDuration: time.Duration(cfg.RateLimitTokenIPBlockTime) * time.Second,
+				Rule:          primary.limit.Rule,
+				KeyType:       KeyTypeTokenIP,
+			},
+		})
+	}
+	return targets
+}
+
+// allowAll checks targets in order and stops at the first one that rejects
+// the request, whose decision is returned. When all of them allow it, the
+// decision with the fewest remaining requests is returned.
+func allowAll(ctx context.Context, rl *limiter.RateLimiter, targets []target) (limiter.Decision, error) {
+	var decision limiter.Decision
+	for i, t := range targets {
+		d, err := rl.Allow(ctx, t.key, t.limit)
+		if err != nil {
+			return limiter.Decision{}, err
+		}
+		if !d.Allowed {
+			return d, nil
+		}
+		if i == 0 || d.Remaining < decision.Remaining {
+			decision = d
+		}
+	}
+	return decision, nil
+}
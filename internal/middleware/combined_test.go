@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/configs"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/limiter"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiterMiddleware_CombinedLimits(t *testing.T) {
+	cfg := &configs.Config{
+		RateLimitIP:               3,
+		RateLimitIPBlockTime:      1,
+		RateLimitToken:            10,
+		RateLimitTokenBlockTime:   1,
+		RateLimitTokenIP:          2,
+		RateLimitTokenIPBlockTime: 1,
+		UnknownTokenPolicy:        configs.UnknownTokenDefault,
+	}
+
+	var decision limiter.Decision
+	newHandler := func(limits ...string) http.Handler {
+		cfg.CombinedLimits = make(map[string]bool)
+		for _, limit := range limits {
+			cfg.CombinedLimits[limit] = true
+		}
+		middleware := RateLimiterMiddleware(cfg, limiter.NewRateLimiter(storage.NewMemoryStorage()))
+		return middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			decision, _ = DecisionFromContext(r.Context())
+			w.WriteHeader(http.StatusOK)
+		}))
+	}
+
+	send := func(handler http.Handler, ip, token string) int {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = ip + ":1234"
+		req.Header.Set("API_KEY", token)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("token only", func(t *testing.T) {
+		handler := newHandler()
+
+		for i := 0; i < 5; i++ {
+			assert.Equal(t, http.StatusOK, send(handler, "10.0.0.1", fmt.Sprintf("token-%d", i)))
+		}
+	})
+
+	t.Run("ip", func(t *testing.T) {
+		handler := newHandler(configs.CombinedIP)
+
+		// One address cycling through tokens hits its IP limit
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, http.StatusOK, send(handler, "10.0.0.1", fmt.Sprintf("token-%d", i)))
+		}
+		assert.Equal(t, http.StatusTooManyRequests, send(handler, "10.0.0.1", "token-3"))
+		assert.Equal(t, "ip:10.0.0.1", decision.Key)
+	})
+
+	t.Run("token-ip", func(t *testing.T) {
+		handler := newHandler(configs.CombinedTokenIP)
+
+		// A token used from one address is limited there only
+		assert.Equal(t, http.StatusOK, send(handler, "10.0.0.1", "leaked"))
+		assert.Equal(t, http.StatusOK, send(handler, "10.0.0.1", "leaked"))
+		assert.Equal(t, KeyTypeTokenIP, decision.KeyType)
+		assert.Equal(t, 0, decision.Remaining)
+		assert.Equal(t, http.StatusTooManyRequests, send(handler, "10.0.0.1", "leaked"))
+
+		assert.Equal(t, http.StatusOK, send(handler, "10.0.0.2", "leaked"))
+		assert.Equal(t, fmt.Sprintf("token-ip:%s:10.0.0.2", configs.HashToken("leaked")), decision.Key)
+	})
+
+	t.Run("requests without token", func(t *testing.T) {
+		handler := newHandler(configs.CombinedIP, configs.CombinedTokenIP)
+
+		assert.Equal(t, http.StatusOK, send(handler, "10.0.0.1", ""))
+		assert.Equal(t, "ip:10.0.0.1", decision.Key)
+		assert.Equal(t, 2, decision.Remaining)
+	})
+}
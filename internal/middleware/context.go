@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/limiter"
+)
+
+type decisionKey struct{}
+
+func withDecision(ctx context.Context, decision limiter.Decision) context.Context {
+	return context.WithValue(ctx, decisionKey{}, decision)
+}
+
+// DecisionFromContext returns the rate-limit decision made for the current
+// request, if the request went through RateLimiterMiddleware
+func DecisionFromContext(ctx context.Context) (limiter.Decision, bool) {
+	decision, ok := ctx.Value(decisionKey{}).(limiter.Decision)
+	return decision, ok
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/configs"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/limiter"
+)
+
+// logDecision logs a sample of the decisions, as configured by
+// cfg.DecisionLogSample. The limiter key, which may hold a client IP, is
+// only logged as a short hash that still correlates lines of one client.
+func logDecision(ctx context.Context, logger *slog.Logger, cfg *configs.Config, decision limiter.Decision, outcome string) {
+	if cfg.DecisionLogSample <= 0 || rand.Float64() >= cfg.DecisionLogSample {
+		return
+	}
+
+	logger.LogAttrs(ctx, slog.LevelInfo, "Rate limit decision",
+		slog.String("outcome", outcome),
+		slog.String("key_type", decision.KeyType),
+		slog.String("identity", identityHash(decision.Key)),
+		slog.String("rule", decision.Rule),
+		slog.Int64("count", decision.Count),
+		slog.Int("limit", decision.Limit),
+		slog.Int("remaining", decision.Remaining),
+		slog.String("reason", string(decision.Reason)),
+	)
+}
+
+// identityHash is the short SHA-256 hash of a limiter key
+func identityHash(key string) string {
+	return configs.ShortHash(configs.HashToken(key))
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/configs"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/limiter"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiterMiddleware_DecisionLog(t *testing.T) {
+	cfg := &configs.Config{
+		RateLimitIP:          1,
+		RateLimitIPBlockTime: 1,
+		DecisionLogSample:    1,
+	}
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	middleware := RateLimiterMiddleware(cfg, limiter.NewRateLimiter(storage.NewMemoryStorage()), WithLogger(logger))
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func() {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "192.168.1.1:1234"
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	send()
+	send()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 2)
+
+	var allowed, rejected map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &allowed))
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &rejected))
+
+	assert.Equal(t, "allowed", allowed["outcome"])
+	assert.Equal(t, "ip", allowed["key_type"])
+	assert.Equal(t, "default", allowed["rule"])
+	assert.Equal(t, float64(1), allowed["count"])
+	assert.Equal(t, "rejected", rejected["outcome"])
+	assert.Equal(t, "exceeded", rejected["reason"])
+
+	// The client is identified by a hash of its key only
+	assert.Equal(t, identityHash("ip:192.168.1.1"), allowed["identity"])
+	assert.Equal(t, allowed["identity"], rejected["identity"])
+	assert.NotContains(t, buf.String(), "192.168.1.1")
+
+	// Sampling can disable the log
+	buf.Reset()
+	cfg.DecisionLogSample = 0
+	send()
+	assert.Empty(t, buf.String())
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/configs"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/limiter"
+)
+
+// setRateLimitHeaders describes the decision to the client. The X-RateLimit-*
+// headers follow the common de facto convention (X-RateLimit-Reset is a Unix
+// timestamp); RateLimit and RateLimit-Policy follow the IETF httpapi draft.
+func setRateLimitHeaders(h http.Header, mode string, decision limiter.Decision) {
+	if mode == configs.HeadersLegacy || mode == configs.HeadersBoth || mode == "" {
+		h.Set("X-RateLimit-Limit", strconv.Itoa(decision.Limit))
+		h.Set("X-RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+		h.Set("X-RateLimit-Reset", strconv.FormatInt(ceilUnix(decision.ResetAt), 10))
+	}
+
+	if mode == configs.HeadersDraft || mode == configs.HeadersBoth {
+		h.Set("RateLimit-Policy", fmt.Sprintf("%q;q=%d;w=%d", decision.Rule, decision.Limit, ceilSeconds(decision.Window)))
+		h.Set("RateLimit", fmt.Sprintf("%q;r=%d;t=%d", decision.Rule, decision.Remaining, ceilSeconds(time.Until(decision.ResetAt))))
+	}
+}
+
+// ceilSeconds rounds a duration up to whole seconds, so clients never retry early
+func ceilSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int((d + time.Second - 1) / time.Second)
+}
+
+func ceilUnix(t time.Time) int64 {
+	if t.Nanosecond() > 0 {
+		return t.Unix() + 1
+	}
+	return t.Unix()
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/configs"
+)
+
+// jwtIdentity verifies the bearer JWT of a request and returns the value
+// of its identity claim and of its tier claim. ok is false when JWT
+// verification is disabled or the request has no valid token.
+func jwtIdentity(cfg *configs.Config, r *http.Request) (identity, tier string, ok bool) {
+	if cfg.JWTVerifier == nil {
+		return "", "", false
+	}
+
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", "", false
+	}
+
+	claims, err := cfg.JWTVerifier.Verify(strings.TrimSpace(token), time.Now())
+	if err != nil {
+		return "", "", false
+	}
+
+	identity, ok = claims.String(cfg.JWTKeyClaim)
+	if !ok {
+		return "", "", false
+	}
+
+	if cfg.JWTTierClaim != "" {
+		tier, _ = claims.String(cfg.JWTTierClaim)
+	}
+	return identity, tier, true
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/configs"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/jwt"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/limiter"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func signToken(secret string, claims map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": "HS256", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestRateLimiterMiddleware_JWT(t *testing.T) {
+	cfg := &configs.Config{
+		RateLimitIP:             1,
+		RateLimitIPBlockTime:    1,
+		RateLimitToken:          2,
+		RateLimitTokenBlockTime: 1,
+		Tiers:                   map[string]configs.TokenConfig{"pro": {Limit: 3, BlockTime: 1}},
+		JWTVerifier:             jwt.NewVerifier([][]byte{[]byte("secret")}, nil),
+		JWTKeyClaim:             "client_id",
+		JWTTierClaim:            "plan",
+	}
+	rateLimiter := limiter.NewRateLimiter(storage.NewMemoryStorage())
+	middleware := RateLimiterMiddleware(cfg, rateLimiter)
+
+	var decision limiter.Decision
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		decision, _ = DecisionFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(token string) int {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "192.168.1.1:1234"
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// The plan claim selects the tier limits
+	pro := signToken("secret", map[string]any{"client_id": "acme", "plan": "pro"})
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, send(pro))
+	}
+	assert.Equal(t, "jwt:acme", decision.Key)
+	assert.Equal(t, KeyTypeJWT, decision.KeyType)
+	assert.Equal(t, 3, decision.Limit)
+	assert.Equal(t, http.StatusTooManyRequests, send(pro))
+
+	// Unknown plans fall back to the default token limits
+	other := signToken("secret", map[string]any{"client_id": "other", "plan": "gold"})
+	assert.Equal(t, http.StatusOK, send(other))
+	assert.Equal(t, 2, decision.Limit)
+
+	// Invalid tokens are limited by IP
+	forged := signToken("wrong", map[string]any{"client_id": "acme", "plan": "pro"})
+	assert.Equal(t, http.StatusOK, send(forged))
+	assert.Equal(t, "ip:192.168.1.1", decision.Key)
+	assert.Equal(t, http.StatusTooManyRequests, send(forged))
+}
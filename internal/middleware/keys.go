@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/configs"
+)
+
+// KeyFunc extracts what a request is limited by. The returned key names its
+// source (e.g. "ip:10.0.0.1" or "header:X-Tenant:acme") so that keys from
+// different sources never collide. ok is false when the request does not
+// carry the value.
+type KeyFunc func(r *http.Request) (key string, ok bool)
+
+// IPKey keys requests on the client IP, aggregated to the configured prefix
+func IPKey(cfg *configs.Config) KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		return fmt.Sprintf("ip:%s", clientSubnet(cfg, r)), true
+	}
+}
+
+// TokenKey keys requests on the hash of the API_KEY header
+func TokenKey() KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		token := r.Header.Get("API_KEY")
+		return tokenKey(token), token != ""
+	}
+}
+
+// JWTKey keys requests on the identity claim of a valid bearer JWT
+func JWTKey(cfg *configs.Config) KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		identity, _, ok := jwtIdentity(cfg, r)
+		return fmt.Sprintf("jwt:%s", identity), ok
+	}
+}
+
+// RouteKey keys requests on their method and path
+func RouteKey() KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		return fmt.Sprintf("route:%s %s", r.Method, r.URL.Path), true
+	}
+}
+
+// HeaderKey keys requests on the value of a header
+func HeaderKey(name string) KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		value := r.Header.Get(name)
+		return fmt.Sprintf("header:%s:%s", name, value), value != ""
+	}
+}
+
+// QueryKey keys requests on a query string parameter
+func QueryKey(name string) KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		value := r.URL.Query().Get(name)
+		return fmt.Sprintf("query:%s:%s", name, value), value != ""
+	}
+}
+
+// CookieKey keys requests on the value of a cookie
+func CookieKey(name string) KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		cookie, err := r.Cookie(name)
+		if err != nil || cookie.Value == "" {
+			return "", false
+		}
+		return fmt.Sprintf("cookie:%s:%s", name, cookie.Value), true
+	}
+}
+
+// BasicAuthKey keys requests on the username of the Basic Authorization
+// header. The password is not checked.
+func BasicAuthKey() KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		user, _, ok := r.BasicAuth()
+		return fmt.Sprintf("basic-auth:%s", user), ok && user != ""
+	}
+}
+
+// PathParamKey keys requests on the segment matched by "{name}" in the
+// rule path
+func PathParamKey(rule configs.Rule, name string) KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		value, ok := rule.PathParam(r.URL.Path, name)
+		return fmt.Sprintf("path:%s:%s", name, value), ok && value != ""
+	}
+}
+
+// CompositeKey combines several keys; it only succeeds when every one of
+// them does
+func CompositeKey(funcs ...KeyFunc) KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		keys := make([]string, 0, len(funcs))
+		for _, fn := range funcs {
+			key, ok := fn(r)
+			if !ok {
+				return "", false
+			}
+			keys = append(keys, key)
+		}
+		return strings.Join(keys, "|"), true
+	}
+}
+
+// keyHasPart reports whether the key strategy of rule includes a part of
+// the given kind
+func keyHasPart(rule configs.Rule, kind string) bool {
+	for _, part := range rule.ParsedKey() {
+		if part.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleKeyFunc builds the KeyFunc of a rule from its key parts
+func ruleKeyFunc(cfg *configs.Config, rule configs.Rule) KeyFunc {
+	parts := rule.ParsedKey()
+
+	funcs := make([]KeyFunc, 0, len(parts))
+	for _, part := range parts {
+		switch part.Kind {
+		case configs.KeyPartToken:
+			funcs = append(funcs, TokenKey())
+		case configs.KeyPartJWT:
+			funcs = append(funcs, JWTKey(cfg))
+		case configs.KeyPartRoute:
+			funcs = append(funcs, RouteKey())
+		case configs.KeyPartHeader:
+			funcs = append(funcs, HeaderKey(part.Arg))
+		case configs.KeyPartQuery:
+			funcs = append(funcs, QueryKey(part.Arg))
+		case configs.KeyPartCookie:
+			funcs = append(funcs, CookieKey(part.Arg))
+		case configs.KeyPartBasicAuth:
+			funcs = append(funcs, BasicAuthKey())
+		case configs.KeyPartPath:
+			funcs = append(funcs, PathParamKey(rule, part.Arg))
+		default:
+			funcs = append(funcs, IPKey(cfg))
+		}
+	}
+
+	if len(funcs) == 1 {
+		return funcs[0]
+	}
+	return CompositeKey(funcs...)
+}
@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/configs"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/limiter"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyFuncs(t *testing.T) {
+	cfg := &configs.Config{IPv4Prefix: 32, IPv6Prefix: 64}
+	rule := configs.Rule{Path: "/tenants/{tenant}"}
+
+	req := httptest.NewRequest("POST", "/tenants/acme/orders?page=2", nil)
+	req.RemoteAddr = "192.168.1.1:1234"
+	req.Header.Set("API_KEY", "abc123")
+	req.Header.Set("User-Agent", "curl/8.0")
+	req.AddCookie(&http.Cookie{Name: "session", Value: "s1"})
+	req.SetBasicAuth("alice", "secret")
+
+	tests := []struct {
+		fn       KeyFunc
+		expected string
+	}{
+		{IPKey(cfg), "ip:192.168.1.1"},
+		{TokenKey(), "token:" + configs.HashToken("abc123")},
+		{RouteKey(), "route:POST /tenants/acme/orders"},
+		{HeaderKey("User-Agent"), "header:User-Agent:curl/8.0"},
+		{QueryKey("page"), "query:page:2"},
+		{CookieKey("session"), "cookie:session:s1"},
+		{BasicAuthKey(), "basic-auth:alice"},
+		{PathParamKey(rule, "tenant"), "path:tenant:acme"},
+		{CompositeKey(TokenKey(), RouteKey()), "token:" + configs.HashToken("abc123") + "|route:POST /tenants/acme/orders"},
+	}
+
+	for _, tt := range tests {
+		key, ok := tt.fn(req)
+		assert.True(t, ok, tt.expected)
+		assert.Equal(t, tt.expected, key)
+	}
+
+	// Missing values are reported, and make composite keys fail
+	empty := httptest.NewRequest("GET", "/other", nil)
+	for _, fn := range []KeyFunc{
+		TokenKey(), HeaderKey("X-Tenant"), QueryKey("page"), CookieKey("session"),
+		BasicAuthKey(), PathParamKey(rule, "tenant"), CompositeKey(RouteKey(), TokenKey()),
+	} {
+		_, ok := fn(empty)
+		assert.False(t, ok)
+	}
+}
+
+func TestRateLimiterMiddleware_RuleKeyFuncs(t *testing.T) {
+	cfg := &configs.Config{
+		RateLimitIP:          10,
+		RateLimitIPBlockTime: 1,
+		IPv4Prefix:           32,
+		Rules: []configs.Rule{
+			{Name: "tenant", Path: "/tenants/{tenant}", Limit: 1, BlockTime: 1, Key: "path:tenant"},
+			{Name: "agent", Path: "/agent", Limit: 1, BlockTime: 1, Key: "ip+header:User-Agent"},
+		},
+	}
+	rateLimiter := limiter.NewRateLimiter(storage.NewMemoryStorage())
+	middleware := RateLimiterMiddleware(cfg, rateLimiter)
+
+	var decision limiter.Decision
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		decision, _ = DecisionFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(path, remoteAddr, userAgent string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("User-Agent", userAgent)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// Path parameters are shared between clients
+	assert.Equal(t, http.StatusOK, send("/tenants/acme", "192.168.1.1:1", "a").Code)
+	assert.Equal(t, http.StatusTooManyRequests, send("/tenants/acme", "192.168.1.2:1", "a").Code)
+	assert.Equal(t, http.StatusOK, send("/tenants/other", "192.168.1.2:1", "a").Code)
+
+	// Composite keys separate user agents behind the same IP
+	assert.Equal(t, http.StatusOK, send("/agent", "192.168.1.3:1", "a").Code)
+	assert.Equal(t, "rule:agent:ip:192.168.1.3|header:User-Agent:a", decision.Key)
+	assert.Equal(t, "ip+header:User-Agent", decision.KeyType)
+
+	assert.Equal(t, http.StatusOK, send("/agent", "192.168.1.3:1", "b").Code)
+	assert.Equal(t, http.StatusTooManyRequests, send("/agent", "192.168.1.3:1", "a").Code)
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/configs"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/limiter"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/metrics"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Option configures RateLimiterMiddleware
+type Option func(*options)
+
+type options struct {
+	metrics *metrics.Metrics
+	logger  *slog.Logger
+	tracer  trace.Tracer
+}
+
+// WithMetrics counts every decision of the middleware in m
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(o *options) {
+		o.metrics = m
+	}
+}
+
+// WithLogger sets the logger of the middleware (slog.Default by default)
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithTracerProvider sets where the spans of the middleware are created
+// (the global OpenTelemetry tracer provider by default)
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(o *options) {
+		o.tracer = tp.Tracer(tracing.TracerName)
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.logger == nil {
+		o.logger = slog.Default()
+	}
+	if o.tracer == nil {
+		o.tracer = otel.Tracer(tracing.TracerName)
+	}
+	return o
+}
+
+// recordDecision reports a decision in every configured output: metrics,
+// the decision log and the span of the request
+func (o *options) recordDecision(ctx context.Context, cfg *configs.Config, result string, decision limiter.Decision) {
+	o.observeDecision(result, decision.KeyType, decision.Rule)
+	logDecision(ctx, o.logger, cfg, decision, result)
+	setSpanDecision(trace.SpanFromContext(ctx), result, decision)
+}
+
+func (o *options) observeDecision(result, keyType, rule string) {
+	if o.metrics != nil {
+		o.metrics.ObserveDecision(result, keyType, rule)
+	}
+}
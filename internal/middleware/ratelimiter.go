@@ -3,86 +3,291 @@ package middleware
 import (
 	"encoding/json"
 	"fmt"
-	"net"
 	"net/http"
+	"net/netip"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/configs"
 	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/limiter"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/metrics"
 )
 
-func RateLimiterMiddleware(cfg *configs.Config, limiter *limiter.RateLimiter) func(http.Handler) http.Handler {
+// RateLimiterMiddleware limits requests using the configuration returned by
+// provider. A *configs.Config can be passed directly for a static setup.
+func RateLimiterMiddleware(provider configs.Provider, limiter *limiter.RateLimiter, opts ...Option) func(http.Handler) http.Handler {
+	o := newOptions(opts)
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ctx := r.Context()
-
-			token := r.Header.Get("API_KEY")
+			// The span covers the decision and the rest of the request
+			ctx, span := o.startSpan(r)
+			defer span.End()
+			r = r.WithContext(ctx)
 
-			var key string
-			var limit int
-			var blockDuration time.Duration
+			cfg := provider.Current()
 
-			if token != "" {
-				// Use token-based limiting (priority over IP)
-				key = fmt.Sprintf("token:%s", token)
-
-				// Get token-specific configuration
-				tokenConfig, exists := cfg.GetTokenConfig(token)
-				if exists {
-					limit = tokenConfig.Limit
-					blockDuration = time.Duration(tokenConfig.BlockTime) * time.Second
-				} else {
-					// Use default token limits
-					limit = cfg.RateLimitToken
-					blockDuration = time.Duration(cfg.RateLimitTokenBlockTime) * time.Second
-				}
-			} else {
-				// Use IP-based limiting
-				ip := getClientIP(r)
-				key = fmt.Sprintf("ip:%s", ip)
-				limit = cfg.RateLimitIP
-				blockDuration = time.Duration(cfg.RateLimitIPBlockTime) * time.Second
+			// Allow and deny lists are checked before any limit
+			addr, _ := netip.ParseAddr(clientIP(cfg, r))
+			switch cfg.CheckAccess(addr, r.Header.Get("API_KEY")) {
+			case configs.AccessDeny:
+				span.SetAttributes(attrDecision.String(decisionDenied))
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusForbidden)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": "access denied",
+				})
+				return
+			case configs.AccessAllow:
+				span.SetAttributes(attrDecision.String(decisionAllowListed))
+				next.ServeHTTP(w, r)
+				return
 			}
 
-			allowed, err := limiter.AllowRequest(ctx, key, limit, blockDuration)
+			primary, err := resolveTarget(cfg, r, newTokenLookup(ctx, cfg, limiter))
 			if err != nil {
+				o.observeDecision(metrics.ResultError, primary.limit.KeyType, primary.limit.Rule)
+				o.logger.ErrorContext(ctx, "Token lookup failed", "rule", primary.limit.Rule, "error", err)
+				recordSpanError(span, err)
 				http.Error(w, "Internal server error", http.StatusInternalServerError)
 				return
 			}
-
-			if !allowed {
+			if primary.exempt {
+				span.SetAttributes(attrDecision.String(decisionExempt))
+				next.ServeHTTP(w, r)
+				return
+			}
+			if primary.unauthorized {
+				span.SetAttributes(attrDecision.String(decisionUnauthorized))
 				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusTooManyRequests)
+				w.WriteHeader(http.StatusUnauthorized)
 				json.NewEncoder(w).Encode(map[string]string{
-					"error": "you have reached the maximum number of requests or actions allowed within a certain time frame",
+					"error": "invalid API token",
 				})
 				return
 			}
 
-			next.ServeHTTP(w, r) // Request is allowed, continue to next handler
+			// Combined limits are checked after the primary one
+			targets := append([]target{primary}, combinedTargets(cfg, r, primary)...)
+			shadow := cfg.ShadowMode || primary.shadow
+			if shadow {
+				targets = shadowTargets(targets)
+			}
+			decision, err := allowAll(ctx, limiter, targets)
+			if err != nil {
+				o.observeDecision(metrics.ResultError, primary.limit.KeyType, primary.limit.Rule)
+				o.logger.ErrorContext(ctx, "Rate limit check failed", "key_type", primary.limit.KeyType, "rule", primary.limit.Rule, "error", err)
+				recordSpanError(span, err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+
+			// In shadow mode the client only learns what would have happened
+			if shadow {
+				decision.Key = strings.TrimPrefix(decision.Key, shadowPrefix)
+				result := metrics.ResultAllowed
+				if !decision.Allowed {
+					result = metrics.ResultShadowRejected
+				}
+				o.recordDecision(ctx, cfg, result, decision)
+
+				recordShadow(ctx, o.logger, w.Header(), decision)
+				next.ServeHTTP(w, r.WithContext(withDecision(ctx, decision)))
+				return
+			}
+
+			setRateLimitHeaders(w.Header(), cfg.RateLimitHeaders, decision)
+
+			if !decision.Allowed {
+				o.recordDecision(ctx, cfg, metrics.ResultRejected, decision)
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(decision.RetryAfter)))
+				writeRejection(w, r, o.logger, cfg.Rejection, decision)
+				return
+			}
+
+			o.recordDecision(ctx, cfg, metrics.ResultAllowed, decision)
+
+			// Request is allowed, continue to next handler with the decision
+			// available to it
+			next.ServeHTTP(w, r.WithContext(withDecision(ctx, decision)))
 		})
 	}
 }
 
-func getClientIP(r *http.Request) string {
-	forwarded := r.Header.Get("X-Forwarded-For")
-	if forwarded != "" {
-		ips := strings.Split(forwarded, ",")
-		if len(ips) > 0 {
-			return strings.TrimSpace(ips[0])
+// DefaultRule names the limits applied when no configured rule matches
+const DefaultRule = "default"
+
+// Key types reported in decisions
+const (
+	KeyTypeIP           = "ip"
+	KeyTypeToken        = "token"
+	KeyTypeJWT          = "jwt"
+	KeyTypeGlobal       = "global"
+	KeyTypeTokenIP      = "token-ip"
+	KeyTypeUnregistered = "unregistered"
+)
+
+// target is what a request is limited by
+type target struct {
+	key   string
+	limit limiter.Limit
+
+	// exempt requests are not rate limited at all
+	exempt bool
+
+	// unauthorized requests carry an unknown token that is rejected
+	unauthorized bool
+
+	// shadow limits are evaluated but never reject requests
+	shadow bool
+}
+
+// resolveTarget returns the limiter key and limit that apply to a request.
+// An error means the token could not be looked up; the returned target
+// then only names the rule.
+func resolveTarget(cfg *configs.Config, r *http.Request, lookupToken tokenLookup) (target, error) {
+	token := r.Header.Get("API_KEY")
+
+	rule, matched := cfg.MatchRule(r.Method, r.URL.Path)
+	if matched {
+		if rule.Exempt {
+			return target{exempt: true}, nil
+		}
+
+		limit := limiter.Limit{
+			Requests:      rule.Limit,
+			Window:        rule.WindowDuration(),
+			BlockDuration: rule.BlockDuration(),
+			Rule:          rule.Name,
+		}
+
+		// Rules keep their limits, but unknown tokens are not keys of their
+		// own: the request is limited as if it carried no token
+		strategy := rule.KeyStrategy()
+		usesToken := strategy == configs.KeyAuto || keyHasPart(rule, configs.KeyPartToken)
+		if usesToken && token != "" && unknownTokenPolicy(cfg) != configs.UnknownTokenDefault {
+			_, known, err := lookupToken(token)
+			if err != nil {
+				limit.KeyType = KeyTypeToken
+				return target{limit: limit}, err
+			}
+			if !known {
+				if unknownTokenPolicy(cfg) == configs.UnknownTokenReject {
+					return target{unauthorized: true}, nil
+				}
+				token = ""
+			}
+		}
+
+		var key string
+		switch strategy {
+		case configs.KeyGlobal:
+			key = "global"
+			limit.KeyType = KeyTypeGlobal
+		case configs.KeyAuto:
+			if token != "" {
+				key = tokenKey(token)
+				limit.KeyType = KeyTypeToken
+			} else if identity, _, ok := jwtIdentity(cfg, r); ok {
+				key = fmt.Sprintf("jwt:%s", identity)
+				limit.KeyType = KeyTypeJWT
+			} else {
+				key, _ = IPKey(cfg)(r)
+				limit.KeyType = KeyTypeIP
+			}
+			limit.Identity = key
+		default:
+			// Requests that do not carry the configured key are limited by IP
+			var ok bool
+			key, ok = ruleKeyFunc(cfg, rule)(r)
+			limit.KeyType = strategy
+			if usesToken && token == "" {
+				ok = false
+			}
+			if !ok {
+				key, _ = IPKey(cfg)(r)
+				limit.KeyType = KeyTypeIP
+			}
+
+			// Token overrides also apply to keys that combine the token
+			// with other parts
+			if ok && token != "" && keyHasPart(rule, configs.KeyPartToken) {
+				limit.Identity = tokenKey(token)
+			}
 		}
+
+		// Rule counters are kept apart from the default ones
+		return target{
+			key:    fmt.Sprintf("rule:%s:%s", rule.Name, key),
+			limit:  limit,
+			shadow: rule.Shadow,
+		}, nil
 	}
 
-	realIP := r.Header.Get("X-Real-IP")
-	if realIP != "" {
-		return realIP
+	limit := limiter.Limit{Window: 1 * time.Second, Rule: DefaultRule}
+
+	if token != "" {
+		// Use token-based limiting (priority over IP)
+		// Get token-specific configuration (or the default token limits)
+		tokenConfig, known, err := lookupToken(token)
+		if err != nil {
+			limit.KeyType = KeyTypeToken
+			return target{limit: limit}, err
+		}
+		switch policy := unknownTokenPolicy(cfg); {
+		case known || policy == configs.UnknownTokenDefault:
+			applyTokenConfig(&limit, tokenConfig)
+			limit.KeyType = KeyTypeToken
+
+			return target{key: tokenKey(token), limit: limit}, nil
+		case policy == configs.UnknownTokenReject:
+			return target{unauthorized: true}, nil
+		case policy == configs.UnknownTokenUnregistered:
+			// Keyed by client so that rotating random tokens does not help
+			tierConfig, _ := cfg.GetTierConfig(configs.UnregisteredTier)
+			applyTokenConfig(&limit, tierConfig)
+			limit.KeyType = KeyTypeUnregistered
+
+			return target{key: fmt.Sprintf("unregistered:%s", clientSubnet(cfg, r)), limit: limit}, nil
+		}
+		// Otherwise the token is ignored and the request limited as if it
+		// carried none
+	}
+
+	// Bearer JWTs are limited by their identity, with the limits of the
+	// tier named in their claims
+	if identity, tier, ok := jwtIdentity(cfg, r); ok {
+		tierConfig, _ := cfg.GetTierConfig(tier)
+		applyTokenConfig(&limit, tierConfig)
+		limit.KeyType = KeyTypeJWT
+
+		return target{key: fmt.Sprintf("jwt:%s", identity), limit: limit}, nil
 	}
 
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
+	// Use IP-based limiting
+	return ipTarget(cfg, r), nil
+}
+
+// ipTarget returns the default IP limit of a request
+func ipTarget(cfg *configs.Config, r *http.Request) target {
+	ip := clientSubnet(cfg, r)
+	limit := limiter.Limit{
+		Requests:      cfg.RateLimitIP,
+		Window:        1 * time.Second,
+		BlockDuration: time.Duration(cfg.RateLimitIPBlockTime) * time.Second,
+		Rule:          DefaultRule,
+		KeyType:       KeyTypeIP,
 	}
 
-	return ip
+	return target{key: fmt.Sprintf("ip:%s", ip), limit: limit}
+}
+
+// applyTokenConfig sets the limits of a token or tier on limit
+func applyTokenConfig(limit *limiter.Limit, tc configs.TokenConfig) {
+	limit.Requests = tc.Limit
+	limit.Window = tc.WindowDuration()
+	limit.BlockDuration = tc.BlockDuration()
+	limit.Quota = tc.Quota
+	limit.QuotaWindow = tc.QuotaDuration()
 }
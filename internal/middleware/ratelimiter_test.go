@@ -1,14 +1,19 @@
 package middleware
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
 	"testing"
 	"time"
 
 	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/configs"
 	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/limiter"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/metrics"
 	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/storage"
 	"github.com/stretchr/testify/assert"
 )
@@ -144,7 +149,7 @@ func TestRateLimiterMiddleware_SpecificTokenConfig(t *testing.T) {
 		RateLimitToken:          10,
 		RateLimitTokenBlockTime: 1,
 		TokenConfigs: map[string]configs.TokenConfig{
-			"abc123": {
+			configs.HashToken("abc123"): {
 				Limit:     3,
 				BlockTime: 2,
 			},
@@ -261,3 +266,708 @@ func TestRateLimiterMiddleware_BlockDuration(t *testing.T) {
 	handler.ServeHTTP(w, req)
 	assert.Equal(t, http.StatusOK, w.Code)
 }
+
+type swappableConfig struct {
+	cfg *configs.Config
+}
+
+func (s *swappableConfig) Current() *configs.Config {
+	return s.cfg
+}
+
+func TestRateLimiterMiddleware_ConfigProvider(t *testing.T) {
+	provider := &swappableConfig{cfg: &configs.Config{
+		RateLimitIP:          1,
+		RateLimitIPBlockTime: 1,
+	}}
+	store := storage.NewMemoryStorage()
+	rateLimiter := limiter.NewRateLimiter(store)
+	middleware := RateLimiterMiddleware(provider, rateLimiter)
+
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "192.168.1.1:1234"
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Swapping the configuration takes effect on the next request
+	provider.cfg = &configs.Config{
+		RateLimitIP:          5,
+		RateLimitIPBlockTime: 1,
+	}
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "192.168.1.1:1234"
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRateLimiterMiddleware_Rules(t *testing.T) {
+	cfg := &configs.Config{
+		RateLimitIP:          10,
+		RateLimitIPBlockTime: 1,
+		Rules: []configs.Rule{
+			{Name: "login", Path: "/login", Methods: []string{"POST"}, Limit: 2, BlockTime: 1},
+			{Name: "public", Path: "/public", Exempt: true},
+		},
+	}
+	store := storage.NewMemoryStorage()
+	rateLimiter := limiter.NewRateLimiter(store)
+	middleware := RateLimiterMiddleware(cfg, rateLimiter)
+
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(method, path string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = "192.168.1.1:1234"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// The login rule has its own, lower limit
+	assert.Equal(t, http.StatusOK, send("POST", "/login"))
+	assert.Equal(t, http.StatusOK, send("POST", "/login"))
+	assert.Equal(t, http.StatusTooManyRequests, send("POST", "/login"))
+
+	// Other routes use the default IP limit with a separate counter
+	assert.Equal(t, http.StatusOK, send("GET", "/login"))
+	assert.Equal(t, http.StatusOK, send("GET", "/"))
+
+	// Exempt routes, including the built-in /health rule, are never limited
+	for i := 0; i < 20; i++ {
+		assert.Equal(t, http.StatusOK, send("GET", "/public/page"))
+		assert.Equal(t, http.StatusOK, send("GET", "/health"))
+	}
+}
+
+func TestRateLimiterMiddleware_OverridesApplyToRules(t *testing.T) {
+	cfg := &configs.Config{
+		RateLimitIP:             10,
+		RateLimitIPBlockTime:    1,
+		RateLimitToken:          10,
+		RateLimitTokenBlockTime: 1,
+		Rules: []configs.Rule{
+			{Name: "api", Path: "/api", Limit: 10, BlockTime: 1},
+			{Name: "orders", Path: "/orders/{id}", Limit: 10, BlockTime: 1, Key: "token+path:id"},
+		},
+	}
+	store := storage.NewMemoryStorage()
+	rateLimiter := limiter.NewRateLimiter(store)
+	middleware := RateLimiterMiddleware(cfg, rateLimiter)
+
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(path string) int {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = "192.168.1.1:1234"
+		req.Header.Set("API_KEY", "abc123")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Overrides set through the admin API are keyed by token, like the
+	// default token limits, and also apply to rules keyed by the token
+	rateLimiter.SetOverride("token:"+configs.HashToken("abc123"), 1, time.Second, time.Minute)
+
+	for _, path := range []string{"/", "/api/items", "/orders/1"} {
+		assert.Equal(t, http.StatusOK, send(path), path)
+		assert.Equal(t, http.StatusTooManyRequests, send(path), path)
+	}
+}
+
+func TestRateLimiterMiddleware_BuiltinRulesMatchExactPaths(t *testing.T) {
+	cfg := &configs.Config{
+		RateLimitIP:          1,
+		RateLimitIPBlockTime: 1,
+	}
+	store := storage.NewMemoryStorage()
+	rateLimiter := limiter.NewRateLimiter(store)
+	middleware := RateLimiterMiddleware(cfg, rateLimiter)
+
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(path string) int {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = "192.168.1.1:1234"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Paths below the built-in endpoints are limited like any other route
+	assert.Equal(t, http.StatusOK, send("/health/x"))
+	assert.Equal(t, http.StatusTooManyRequests, send("/health/x"))
+
+	// /metrics is served on the metrics port, so in gateway mode the path
+	// is proxied and limited like any other
+	assert.Equal(t, http.StatusTooManyRequests, send("/metrics"))
+
+	// The endpoints themselves stay exempt
+	assert.Equal(t, http.StatusOK, send("/health"))
+	assert.Equal(t, http.StatusOK, send("/readyz"))
+}
+
+func TestRateLimiterMiddleware_ProbesExemptFromCatchAllRule(t *testing.T) {
+	cfg := &configs.Config{
+		Rules: []configs.Rule{{Name: "all", Path: "/", Limit: 1, BlockTime: 60}},
+	}
+	middleware := RateLimiterMiddleware(cfg, limiter.NewRateLimiter(storage.NewMemoryStorage()))
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(path string) int {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = "192.168.1.1:1234"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, send("/readyz"))
+		assert.Equal(t, http.StatusOK, send("/livez"))
+	}
+
+	assert.Equal(t, http.StatusOK, send("/api"))
+	assert.Equal(t, http.StatusTooManyRequests, send("/api"))
+}
+
+func TestRateLimiterMiddleware_RuleKeyStrategies(t *testing.T) {
+	cfg := &configs.Config{
+		Rules: []configs.Rule{
+			{Name: "search", Path: "/search", Limit: 1, BlockTime: 1, Key: configs.KeyGlobal},
+			{Name: "upload", Path: "/upload", Limit: 1, BlockTime: 1, Key: configs.KeyIP},
+		},
+	}
+	store := storage.NewMemoryStorage()
+	rateLimiter := limiter.NewRateLimiter(store)
+	middleware := RateLimiterMiddleware(cfg, rateLimiter)
+
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(path, ip, token string) int {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = ip + ":1234"
+		if token != "" {
+			req.Header.Set("API_KEY", token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Global: every client shares the same counter
+	assert.Equal(t, http.StatusOK, send("/search", "10.0.0.1", ""))
+	assert.Equal(t, http.StatusTooManyRequests, send("/search", "10.0.0.2", ""))
+
+	// IP: tokens do not give a separate counter
+	assert.Equal(t, http.StatusOK, send("/upload", "10.0.0.1", "token-a"))
+	assert.Equal(t, http.StatusTooManyRequests, send("/upload", "10.0.0.1", "token-b"))
+	assert.Equal(t, http.StatusOK, send("/upload", "10.0.0.2", "token-a"))
+}
+
+func TestRateLimiterMiddleware_Headers(t *testing.T) {
+	cfg := &configs.Config{
+		RateLimitIP:          2,
+		RateLimitIPBlockTime: 30,
+	}
+	store := storage.NewMemoryStorage()
+	rateLimiter := limiter.NewRateLimiter(store)
+	middleware := RateLimiterMiddleware(cfg, rateLimiter)
+
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "192.168.1.1:1234"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send()
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Remaining"))
+	assert.NotEmpty(t, w.Header().Get("X-RateLimit-Reset"))
+	assert.Empty(t, w.Header().Get("RateLimit"))
+	assert.Empty(t, w.Header().Get("Retry-After"))
+
+	w = send()
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+
+	// Over the limit: Retry-After reflects the block duration
+	w = send()
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+
+	// Already blocked: Retry-After is computed from the remaining block time
+	w = send()
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+}
+
+func TestRateLimiterMiddleware_DraftHeaders(t *testing.T) {
+	cfg := &configs.Config{
+		RateLimitIP:          5,
+		RateLimitIPBlockTime: 1,
+		RateLimitHeaders:     configs.HeadersDraft,
+		Rules: []configs.Rule{
+			{Name: "search", Path: "/search", Limit: 10, Window: 60, BlockTime: 1},
+		},
+	}
+	store := storage.NewMemoryStorage()
+	rateLimiter := limiter.NewRateLimiter(store)
+	middleware := RateLimiterMiddleware(cfg, rateLimiter)
+
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest("GET", "/search", nil)
+	req.RemoteAddr = "192.168.1.1:1234"
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, `"search";q=10;w=60`, w.Header().Get("RateLimit-Policy"))
+	assert.Equal(t, `"search";r=9;t=60`, w.Header().Get("RateLimit"))
+	assert.Empty(t, w.Header().Get("X-RateLimit-Limit"))
+}
+
+func TestRateLimiterMiddleware_DecisionInContext(t *testing.T) {
+	cfg := &configs.Config{
+		RateLimitIP:             5,
+		RateLimitIPBlockTime:    1,
+		RateLimitToken:          10,
+		RateLimitTokenBlockTime: 1,
+	}
+	store := storage.NewMemoryStorage()
+	rateLimiter := limiter.NewRateLimiter(store)
+	middleware := RateLimiterMiddleware(cfg, rateLimiter)
+
+	var decision limiter.Decision
+	var found bool
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		decision, found = DecisionFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("API_KEY", "test-token")
+	req.RemoteAddr = "192.168.1.1:1234"
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	assert.True(t, found)
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, limiter.ReasonAllowed, decision.Reason)
+	assert.Equal(t, DefaultRule, decision.Rule)
+	assert.Equal(t, KeyTypeToken, decision.KeyType)
+	assert.Equal(t, 10, decision.Limit)
+	assert.Equal(t, 9, decision.Remaining)
+}
+
+func TestRateLimiterMiddleware_IPv6Aggregation(t *testing.T) {
+	cfg := &configs.Config{
+		RateLimitIP:          2,
+		RateLimitIPBlockTime: 1,
+		IPv4Prefix:           32,
+		IPv6Prefix:           64,
+	}
+	store := storage.NewMemoryStorage()
+	rateLimiter := limiter.NewRateLimiter(store)
+	middleware := RateLimiterMiddleware(cfg, rateLimiter)
+
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(remoteAddr string) int {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Rotating addresses inside the same /64 does not escape the limit
+	assert.Equal(t, http.StatusOK, send("[2001:db8:1:2::1]:1234"))
+	assert.Equal(t, http.StatusOK, send("[2001:db8:1:2::2]:1234"))
+	assert.Equal(t, http.StatusTooManyRequests, send("[2001:db8:1:2:ffff::3]:1234"))
+
+	// Another /64 has its own counter
+	assert.Equal(t, http.StatusOK, send("[2001:db8:1:3::1]:1234"))
+
+	// IPv4-mapped addresses share the counter of the IPv4 address
+	assert.Equal(t, http.StatusOK, send("192.168.1.1:1234"))
+	assert.Equal(t, http.StatusOK, send("[::ffff:192.168.1.1]:1234"))
+	assert.Equal(t, http.StatusTooManyRequests, send("192.168.1.1:1234"))
+}
+
+func TestRateLimiterMiddleware_AccessLists(t *testing.T) {
+	provider := &swappableConfig{cfg: &configs.Config{
+		RateLimitIP:          1,
+		RateLimitIPBlockTime: 1,
+		AllowedIPs:           configs.NewPrefixSet([]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}),
+		DeniedIPs:            configs.NewPrefixSet([]netip.Prefix{netip.MustParsePrefix("203.0.113.0/24")}),
+		DeniedTokens:         map[string]bool{configs.HashToken("revoked"): true},
+	}}
+	store := storage.NewMemoryStorage()
+	rateLimiter := limiter.NewRateLimiter(store)
+	middleware := RateLimiterMiddleware(provider, rateLimiter)
+
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(remoteAddr, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = remoteAddr
+		if token != "" {
+			req.Header.Set("API_KEY", token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// Allowed networks are never limited and leave no counters behind
+	for i := 0; i < 5; i++ {
+		w := send("10.1.2.3:1234", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("X-RateLimit-Limit"))
+	}
+	count, _ := rateLimiter.GetCurrentCount(context.Background(), "ip:10.1.2.3")
+	assert.Equal(t, int64(0), count)
+
+	// Denied networks and tokens are rejected before the limiter
+	w := send("203.0.113.9:1234", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "access denied")
+
+	w = send("10.1.2.3:1234", "revoked")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// Lists follow configuration reloads
+	provider.cfg = &configs.Config{RateLimitIP: 1, RateLimitIPBlockTime: 1}
+	assert.Equal(t, http.StatusOK, send("203.0.113.9:1234", "").Code)
+	assert.Equal(t, http.StatusOK, send("10.1.2.3:1234", "").Code)
+	assert.Equal(t, http.StatusTooManyRequests, send("10.1.2.3:1234", "").Code)
+}
+
+func TestRateLimiterMiddleware_TierQuota(t *testing.T) {
+	cfg := &configs.Config{
+		RateLimitIP:             10,
+		RateLimitIPBlockTime:    1,
+		RateLimitToken:          10,
+		RateLimitTokenBlockTime: 1,
+		TokenConfigs: map[string]configs.TokenConfig{
+			configs.HashToken("free-token"): {Limit: 10, BlockTime: 1, Window: 60, Quota: 2, QuotaWindow: 3600, Tier: "free"},
+		},
+	}
+	rateLimiter := limiter.NewRateLimiter(storage.NewMemoryStorage())
+	middleware := RateLimiterMiddleware(cfg, rateLimiter)
+
+	var decision limiter.Decision
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		decision, _ = DecisionFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "192.168.1.1:1234"
+		req.Header.Set("API_KEY", "free-token")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, send().Code)
+	assert.Equal(t, 60*time.Second, decision.Window)
+	assert.Equal(t, 1, decision.Remaining)
+
+	assert.Equal(t, http.StatusOK, send().Code)
+
+	w := send()
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "3600", w.Header().Get("Retry-After"))
+}
+
+func TestRateLimiterMiddleware_TokenRegistry(t *testing.T) {
+	cfg := &configs.Config{
+		RateLimitIP:             10,
+		RateLimitIPBlockTime:    1,
+		RateLimitToken:          10,
+		RateLimitTokenBlockTime: 1,
+		Tiers:                   map[string]configs.TokenConfig{"pro": {Limit: 2, BlockTime: 1}},
+	}
+	rateLimiter := limiter.NewRateLimiter(storage.NewMemoryStorage())
+	middleware := RateLimiterMiddleware(cfg, rateLimiter)
+
+	hash := configs.HashToken("stored-token")
+	rateLimiter.RegisterToken(context.Background(), hash, storage.TokenRecord{Name: "acme", Tier: "pro"})
+
+	var decision limiter.Decision
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		decision, _ = DecisionFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func() int {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "192.168.1.1:1234"
+		req.Header.Set("API_KEY", "stored-token")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Registered tokens get their tier limits and are keyed by hash
+	assert.Equal(t, http.StatusOK, send())
+	assert.Equal(t, "token:"+hash, decision.Key)
+	assert.Equal(t, 2, decision.Limit)
+	assert.Equal(t, http.StatusOK, send())
+	assert.Equal(t, http.StatusTooManyRequests, send())
+
+	// The raw token never reaches storage
+	counters, _ := rateLimiter.ListCounters(context.Background())
+	for _, counter := range counters {
+		assert.NotContains(t, counter.Key, "stored-token")
+	}
+}
+
+// registryDownStorage fails every token registry lookup
+type registryDownStorage struct {
+	*storage.MemoryStorage
+}
+
+func (s registryDownStorage) GetToken(ctx context.Context, hash string) (storage.TokenRecord, bool, error) {
+	return storage.TokenRecord{}, false, errors.New("connection refused")
+}
+
+func TestRateLimiterMiddleware_TokenRegistryUnavailable(t *testing.T) {
+	for _, policy := range []string{configs.UnknownTokenReject, configs.UnknownTokenIP, configs.UnknownTokenUnregistered} {
+		cfg := &configs.Config{
+			RateLimitIP:             10,
+			RateLimitIPBlockTime:    1,
+			RateLimitToken:          10,
+			RateLimitTokenBlockTime: 1,
+			UnknownTokenPolicy:      policy,
+			TokenConfigs: map[string]configs.TokenConfig{
+				configs.HashToken("known-token"): {Limit: 10, BlockTime: 1},
+			},
+			Rules: []configs.Rule{{Name: "api", Path: "/api", Limit: 10, BlockTime: 1}},
+		}
+		rateLimiter := limiter.NewRateLimiter(registryDownStorage{storage.NewMemoryStorage()})
+		handler := RateLimiterMiddleware(cfg, rateLimiter)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		send := func(path, token string) int {
+			req := httptest.NewRequest("GET", path, nil)
+			req.RemoteAddr = "192.168.1.1:1234"
+			req.Header.Set("API_KEY", token)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			return w.Code
+		}
+
+		// Tokens that need the registry are neither trusted nor rejected
+		// while it is unreachable
+		assert.Equal(t, http.StatusInternalServerError, send("/", "made-up-token"), policy)
+		assert.Equal(t, http.StatusInternalServerError, send("/api", "made-up-token"), policy)
+
+		// Configured tokens do not need it
+		assert.Equal(t, http.StatusOK, send("/", "known-token"), policy)
+		assert.Equal(t, http.StatusOK, send("/api", "known-token"), policy)
+	}
+}
+
+func TestRateLimiterMiddleware_UnknownTokens(t *testing.T) {
+	cfg := &configs.Config{
+		RateLimitIP:             2,
+		RateLimitIPBlockTime:    1,
+		RateLimitToken:          10,
+		RateLimitTokenBlockTime: 1,
+		TokenConfigs: map[string]configs.TokenConfig{
+			configs.HashToken("known-token"): {Limit: 10, BlockTime: 1},
+		},
+		Tiers: map[string]configs.TokenConfig{
+			configs.UnregisteredTier: {Limit: 3, BlockTime: 1},
+		},
+	}
+
+	var decision limiter.Decision
+	newHandler := func(policy string) http.Handler {
+		cfg.UnknownTokenPolicy = policy
+		middleware := RateLimiterMiddleware(cfg, limiter.NewRateLimiter(storage.NewMemoryStorage()))
+		return middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			decision, _ = DecisionFromContext(r.Context())
+			w.WriteHeader(http.StatusOK)
+		}))
+	}
+
+	send := func(handler http.Handler, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "192.168.1.1:1234"
+		req.Header.Set("API_KEY", token)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("reject", func(t *testing.T) {
+		handler := newHandler(configs.UnknownTokenReject)
+
+		w := send(handler, "random-token")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		var response map[string]string
+		json.NewDecoder(w.Body).Decode(&response)
+		assert.Equal(t, "invalid API token", response["error"])
+
+		assert.Equal(t, http.StatusOK, send(handler, "known-token").Code)
+	})
+
+	t.Run("ip", func(t *testing.T) {
+		handler := newHandler(configs.UnknownTokenIP)
+
+		// Every random token counts against the same IP
+		assert.Equal(t, http.StatusOK, send(handler, "random-1").Code)
+		assert.Equal(t, "ip:192.168.1.1", decision.Key)
+		assert.Equal(t, http.StatusOK, send(handler, "random-2").Code)
+		assert.Equal(t, http.StatusTooManyRequests, send(handler, "random-3").Code)
+
+		// Known tokens are not affected
+		assert.Equal(t, http.StatusOK, send(handler, "known-token").Code)
+	})
+
+	t.Run("unregistered", func(t *testing.T) {
+		handler := newHandler(configs.UnknownTokenUnregistered)
+
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, http.StatusOK, send(handler, fmt.Sprintf("random-%d", i)).Code)
+		}
+		assert.Equal(t, "unregistered:192.168.1.1", decision.Key)
+		assert.Equal(t, KeyTypeUnregistered, decision.KeyType)
+		assert.Equal(t, http.StatusTooManyRequests, send(handler, "random-4").Code)
+
+		// The IP counter is separate
+		assert.Equal(t, http.StatusOK, send(handler, "").Code)
+	})
+
+	t.Run("default", func(t *testing.T) {
+		handler := newHandler(configs.UnknownTokenDefault)
+
+		assert.Equal(t, http.StatusOK, send(handler, "random-token").Code)
+		assert.Equal(t, "token:"+configs.HashToken("random-token"), decision.Key)
+		assert.Equal(t, 10, decision.Limit)
+	})
+}
+
+func TestRateLimiterMiddleware_UnknownTokensOnTokenRules(t *testing.T) {
+	cfg := &configs.Config{
+		RateLimitIP:             10,
+		RateLimitIPBlockTime:    1,
+		RateLimitToken:          10,
+		RateLimitTokenBlockTime: 1,
+		TokenConfigs: map[string]configs.TokenConfig{
+			configs.HashToken("known-token"): {Limit: 10, BlockTime: 1},
+		},
+		Rules: []configs.Rule{
+			{Name: "token", Path: "/token", Limit: 1, BlockTime: 1, Key: "token"},
+			{Name: "orders", Path: "/orders/{id}", Limit: 1, BlockTime: 1, Key: "token+path:id"},
+		},
+	}
+
+	var decision limiter.Decision
+	newHandler := func(policy string) http.Handler {
+		cfg.UnknownTokenPolicy = policy
+		middleware := RateLimiterMiddleware(cfg, limiter.NewRateLimiter(storage.NewMemoryStorage()))
+		return middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			decision, _ = DecisionFromContext(r.Context())
+			w.WriteHeader(http.StatusOK)
+		}))
+	}
+
+	send := func(handler http.Handler, path, token string) int {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = "192.168.1.1:1234"
+		req.Header.Set("API_KEY", token)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("reject", func(t *testing.T) {
+		handler := newHandler(configs.UnknownTokenReject)
+
+		for i := 0; i < 5; i++ {
+			assert.Equal(t, http.StatusUnauthorized, send(handler, "/token", fmt.Sprintf("random-%d", i)))
+			assert.Equal(t, http.StatusUnauthorized, send(handler, "/orders/1", fmt.Sprintf("random-%d", i)))
+		}
+		assert.Equal(t, http.StatusOK, send(handler, "/token", "known-token"))
+	})
+
+	t.Run("ip", func(t *testing.T) {
+		handler := newHandler(configs.UnknownTokenIP)
+
+		// Random tokens share the rule counter of the client IP
+		assert.Equal(t, http.StatusOK, send(handler, "/token", "random-1"))
+		assert.Equal(t, "rule:token:ip:192.168.1.1", decision.Key)
+		assert.Equal(t, KeyTypeIP, decision.KeyType)
+		assert.Equal(t, http.StatusTooManyRequests, send(handler, "/token", "random-2"))
+
+		assert.Equal(t, http.StatusOK, send(handler, "/orders/1", "random-3"))
+		assert.Equal(t, http.StatusTooManyRequests, send(handler, "/orders/1", "random-4"))
+
+		// Known tokens keep their own counters
+		assert.Equal(t, http.StatusOK, send(handler, "/token", "known-token"))
+		assert.Equal(t, KeyTypeToken, decision.KeyType)
+	})
+}
+
+func TestRateLimiterMiddleware_Metrics(t *testing.T) {
+	cfg := &configs.Config{
+		RateLimitIP:          1,
+		RateLimitIPBlockTime: 1,
+	}
+	m := metrics.New()
+	middleware := RateLimiterMiddleware(cfg, limiter.NewRateLimiter(storage.NewMemoryStorage()), WithMetrics(m))
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "192.168.1.1:1234"
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+
+	assert.Contains(t, body, `ratelimiter_decisions_total{key_type="ip",result="allowed",rule="default"} 1`)
+	assert.Contains(t, body, `ratelimiter_decisions_total{key_type="ip",result="rejected",rule="default"} 2`)
+	assert.NotContains(t, body, "192.168.1.1")
+}
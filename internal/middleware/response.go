@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"bytes"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/configs"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/limiter"
+)
+
+// rejectionData holds the variables available to rejection templates
+type rejectionData struct {
+	Status     int
+	Limit      int
+	Remaining  int
+	RetryAfter int
+	Reset      int64
+	Window     int
+	KeyType    string
+	Rule       string
+	Reason     string
+}
+
+// defaultRejection is used by configurations built without LoadConfig
+var defaultRejection = configs.DefaultRejection()
+
+// writeRejection sends the configured rejection response for decision
+func writeRejection(w http.ResponseWriter, r *http.Request, logger *slog.Logger, rejection *configs.RejectionResponse, decision limiter.Decision) {
+	if rejection == nil {
+		rejection = defaultRejection
+	}
+
+	name := rejection.Format
+	if rejection.Negotiate {
+		name = negotiateFormat(r.Header.Get("Accept"), rejection)
+	}
+	format := rejection.Formats[name]
+
+	data := rejectionData{
+		Status:     rejection.Status,
+		Limit:      decision.Limit,
+		Remaining:  decision.Remaining,
+		RetryAfter: ceilSeconds(decision.RetryAfter),
+		Reset:      ceilUnix(decision.ResetAt),
+		Window:     ceilSeconds(decision.Window),
+		KeyType:    decision.KeyType,
+		Rule:       decision.Rule,
+		Reason:     string(decision.Reason),
+	}
+
+	// Render first so that a failing template does not leave a half
+	// written response behind
+	var body bytes.Buffer
+	if err := format.Template.Execute(&body, data); err != nil {
+		logger.ErrorContext(r.Context(), "Rejection template failed", "format", name, "error", err)
+		body.Reset()
+	}
+
+	w.Header().Set("Content-Type", format.ContentType)
+	w.WriteHeader(rejection.Status)
+	w.Write(body.Bytes())
+}
+
+// negotiateFormat picks the format whose content type the Accept header
+// prefers. Requests without a preference, or that accept none of the
+// formats, get the default format.
+func negotiateFormat(accept string, rejection *configs.RejectionResponse) string {
+	if accept == "" {
+		return rejection.Format
+	}
+
+	// The default format wins ties, then formats in name order
+	candidates := []string{rejection.Format}
+	for _, name := range rejection.FormatNames() {
+		if name != rejection.Format {
+			candidates = append(candidates, name)
+		}
+	}
+
+	best, bestQ := rejection.Format, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		if q <= bestQ {
+			continue
+		}
+
+		for _, name := range candidates {
+			if mediaTypeMatches(mediaType, rejection.Formats[name].ContentType) {
+				best, bestQ = name, q
+				break
+			}
+		}
+	}
+	return best
+}
+
+// mediaTypeMatches reports whether an accepted media type, which may be a
+// wildcard like "*/*" or "text/*", covers contentType
+func mediaTypeMatches(accepted, contentType string) bool {
+	actual, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	if accepted == "*/*" || accepted == actual {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(accepted, "/*"); ok {
+		return strings.HasPrefix(actual, prefix+"/")
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"text/template"
+
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/configs"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/limiter"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	rejection := configs.DefaultRejection()
+
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", configs.FormatJSON},
+		{"*/*", configs.FormatJSON},
+		{"text/html", configs.FormatHTML},
+		{"text/html, application/json;q=0.9", configs.FormatHTML},
+		{"text/html;q=0.5, application/json", configs.FormatJSON},
+		{"text/plain", configs.FormatText},
+		{"text/*", configs.FormatHTML},
+		{"application/problem+json", configs.FormatProblem},
+		{"image/png", configs.FormatJSON},
+		{"text/html;q=0", configs.FormatJSON},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, negotiateFormat(tt.accept, rejection), "Accept: %q", tt.accept)
+	}
+}
+
+func TestRateLimiterMiddleware_RejectionResponse(t *testing.T) {
+	rejection := configs.DefaultRejection()
+	rejection.Status = http.StatusServiceUnavailable
+	rejection.Formats[configs.FormatText] = configs.ResponseFormat{
+		ContentType: "text/plain; charset=utf-8",
+		Template:    mustTemplate(t, "{{.KeyType}} limit of {{.Limit}} reached, retry in {{.RetryAfter}}s ({{.Reason}})"),
+	}
+
+	cfg := &configs.Config{
+		RateLimitIP:          1,
+		RateLimitIPBlockTime: 30,
+		Rejection:            rejection,
+	}
+	middleware := RateLimiterMiddleware(cfg, limiter.NewRateLimiter(storage.NewMemoryStorage()))
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "192.168.1.1:1234"
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, send("").Code)
+
+	w := send("text/plain")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "ip limit of 1 reached, retry in 30s (exceeded)", w.Body.String())
+
+	w = send("application/problem+json")
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{
+		"type": "about:blank",
+		"title": "Too Many Requests",
+		"status": 503,
+		"detail": "you have reached the maximum of 1 requests allowed",
+		"retry_after": 30
+	}`, w.Body.String())
+
+	// Without negotiation the default format is always sent
+	rejection.Negotiate = false
+	w = send("text/plain")
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+}
+
+func mustTemplate(t *testing.T, body string) configs.Template {
+	t.Helper()
+
+	tmpl, err := template.New("test").Parse(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tmpl
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/limiter"
+)
+
+// Values of the X-RateLimit-Shadow header
+const (
+	ShadowAllow  = "allow"
+	ShadowReject = "reject"
+)
+
+// shadowPrefix keeps the counters and blocks of shadow decisions apart from
+// the enforced ones, so that turning shadow mode off does not enforce blocks
+// created while it was on
+const shadowPrefix = "shadow:"
+
+// shadowTargets moves targets under shadowPrefix. Overrides still apply
+// under the original key.
+func shadowTargets(targets []target) []target {
+	shadowed := make([]target, len(targets))
+	for i, t := range targets {
+		if t.limit.Identity == "" {
+			t.limit.Identity = t.key
+		}
+		t.key = shadowPrefix + t.key
+		shadowed[i] = t
+	}
+	return shadowed
+}
+
+// recordShadow reports a decision that is not enforced: the client gets the
+// X-RateLimit-Shadow header and requests that would have been rejected are
+// logged, so limits can be tuned before they are enforced. Keys can hold
+// cookies or credentials, so only their hash is logged, as in decision logs.
+func recordShadow(ctx context.Context, logger *slog.Logger, h http.Header, decision limiter.Decision) {
+	if decision.Allowed {
+		h.Set("X-RateLimit-Shadow", ShadowAllow)
+		return
+	}
+
+	h.Set("X-RateLimit-Shadow", ShadowReject)
+	logger.WarnContext(ctx, "Shadow mode: request would be rejected",
+		"identity", identityHash(decision.Key),
+		"key_type", decision.KeyType,
+		"rule", decision.Rule,
+		"reason", decision.Reason,
+		"limit", decision.Limit,
+	)
+}
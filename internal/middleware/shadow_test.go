@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/configs"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/limiter"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiterMiddleware_ShadowMode(t *testing.T) {
+	cfg := &configs.Config{
+		RateLimitIP:          2,
+		RateLimitIPBlockTime: 1,
+		ShadowMode:           true,
+	}
+	middleware := RateLimiterMiddleware(cfg, limiter.NewRateLimiter(storage.NewMemoryStorage()))
+
+	var decision limiter.Decision
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		decision, _ = DecisionFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "192.168.1.1:1234"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		w := send()
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, ShadowAllow, w.Header().Get("X-RateLimit-Shadow"))
+	}
+
+	// Over the limit the request still goes through, with the decision
+	// that would have rejected it
+	w := send()
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, ShadowReject, w.Header().Get("X-RateLimit-Shadow"))
+	assert.Empty(t, w.Header().Get("X-RateLimit-Limit"))
+	assert.Empty(t, w.Header().Get("Retry-After"))
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, limiter.ReasonExceeded, decision.Reason)
+}
+
+func TestRateLimiterMiddleware_ShadowRule(t *testing.T) {
+	cfg := &configs.Config{
+		RateLimitIP:          1,
+		RateLimitIPBlockTime: 1,
+		Rules: []configs.Rule{
+			{Name: "search", Path: "/search", Limit: 1, BlockTime: 1, Shadow: true},
+		},
+	}
+	middleware := RateLimiterMiddleware(cfg, limiter.NewRateLimiter(storage.NewMemoryStorage()))
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = "192.168.1.1:1234"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, send("/search").Code)
+	w := send("/search")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, ShadowReject, w.Header().Get("X-RateLimit-Shadow"))
+
+	// Other limits are still enforced
+	assert.Equal(t, http.StatusOK, send("/").Code)
+	w = send("/")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Empty(t, w.Header().Get("X-RateLimit-Shadow"))
+}
+
+func TestRateLimiterMiddleware_ShadowLogHashesKey(t *testing.T) {
+	cfg := &configs.Config{
+		RateLimitIP:          10,
+		RateLimitIPBlockTime: 1,
+		Rules: []configs.Rule{
+			{Name: "s", Path: "/s", Limit: 1, BlockTime: 1, Key: "cookie:session", Shadow: true},
+		},
+	}
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	middleware := RateLimiterMiddleware(cfg, limiter.NewRateLimiter(storage.NewMemoryStorage()), WithLogger(logger))
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("GET", "/s", nil)
+		req.AddCookie(&http.Cookie{Name: "session", Value: "SECRET-SESSION"})
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// The would-be rejection is logged by the hash of its key only
+	assert.Contains(t, logs.String(), "Shadow mode: request would be rejected")
+	assert.Contains(t, logs.String(), "identity="+identityHash("rule:s:cookie:session:SECRET-SESSION"))
+	assert.NotContains(t, logs.String(), "SECRET-SESSION")
+}
+
+func TestRateLimiterMiddleware_ShadowStateIsNotEnforced(t *testing.T) {
+	cfg := &configs.Config{
+		RateLimitIP:          1,
+		RateLimitIPBlockTime: 60,
+		ShadowMode:           true,
+	}
+	store := storage.NewMemoryStorage()
+	middleware := RateLimiterMiddleware(cfg, limiter.NewRateLimiter(store))
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "192.168.1.1:1234"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, send().Code)
+	}
+	assert.Equal(t, ShadowReject, send().Header().Get("X-RateLimit-Shadow"))
+
+	// Blocks created in shadow mode are not enforced once it is turned off
+	blocked, err := store.IsBlocked(t.Context(), "ip:192.168.1.1")
+	assert.NoError(t, err)
+	assert.False(t, blocked)
+
+	cfg.ShadowMode = false
+	cfg.RateLimitIP = 1000
+
+	w := send()
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "999", w.Header().Get("X-RateLimit-Remaining"))
+}
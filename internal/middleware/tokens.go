@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/configs"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/limiter"
+)
+
+// tokenLookup resolves the limits of a raw API token by its hash. known is
+// false for tokens that are neither configured nor registered in storage,
+// which get the default tier; what happens to them is decided by
+// unknownTokenPolicy.
+type tokenLookup func(token string) (tc configs.TokenConfig, known bool, err error)
+
+// newTokenLookup looks tokens up in the configuration first and then in the
+// token registry of the storage. Registry failures are returned rather than
+// guessed at: treating the token as known would let made-up tokens through
+// the unknown token policy, and as unknown would reject registered ones.
+func newTokenLookup(ctx context.Context, cfg *configs.Config, rl *limiter.RateLimiter) tokenLookup {
+	return func(token string) (configs.TokenConfig, bool, error) {
+		hash := configs.HashToken(token)
+		if tc, ok := cfg.GetTokenConfigByHash(hash); ok {
+			return tc, true, nil
+		}
+
+		record, ok, err := rl.LookupToken(ctx, hash)
+		if err != nil {
+			return configs.TokenConfig{}, false, fmt.Errorf("failed to look up token: %w", err)
+		}
+		if !ok {
+			fallback, _ := cfg.GetTierConfig(configs.DefaultTier)
+			return fallback, false, nil
+		}
+
+		tier := record.Tier
+		if tier == "" {
+			tier = configs.DefaultTier
+		}
+		tc, _ := cfg.GetTierConfig(tier)
+		tc.Name = record.Name
+		if record.Limit > 0 {
+			tc.Limit = record.Limit
+		}
+		if record.BlockTime > 0 {
+			tc.BlockTime = record.BlockTime
+		}
+		return tc, true, nil
+	}
+}
+
+// unknownTokenPolicy returns how unknown tokens are handled. Configurations
+// built without LoadConfig keep the behavior from before the policy existed.
+func unknownTokenPolicy(cfg *configs.Config) string {
+	if cfg.UnknownTokenPolicy == "" {
+		return configs.UnknownTokenDefault
+	}
+	return cfg.UnknownTokenPolicy
+}
+
+// tokenKey is the limiter key of a raw token; only its hash is used
+func tokenKey(token string) string {
+	return "token:" + configs.HashToken(token)
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/limiter"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/metrics"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Span attributes of the middleware
+const (
+	attrDecision  = attribute.Key("ratelimit.decision")
+	attrReason    = attribute.Key("ratelimit.reason")
+	attrRule      = attribute.Key("ratelimit.rule")
+	attrKeyType   = attribute.Key("ratelimit.key_type")
+	attrLimit     = attribute.Key("ratelimit.limit")
+	attrRemaining = attribute.Key("ratelimit.remaining")
+)
+
+// Decisions reported on spans besides the metrics results
+const (
+	decisionDenied       = "denied"
+	decisionAllowListed  = "allow_listed"
+	decisionExempt       = "exempt"
+	decisionUnauthorized = "unauthorized"
+)
+
+// startSpan starts the span of a request, as a child of the trace context
+// propagated in its headers, if any
+func (o *options) startSpan(r *http.Request) (context.Context, trace.Span) {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	return o.tracer.Start(ctx, "RateLimiterMiddleware",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.request.method", r.Method),
+			attribute.String("url.path", r.URL.Path),
+		),
+	)
+}
+
+func setSpanDecision(span trace.Span, result string, decision limiter.Decision) {
+	span.SetAttributes(
+		attrDecision.String(result),
+		attrReason.String(string(decision.Reason)),
+		attrRule.String(decision.Rule),
+		attrKeyType.String(decision.KeyType),
+		attrLimit.Int(decision.Limit),
+		attrRemaining.Int(decision.Remaining),
+	)
+}
+
+func recordSpanError(span trace.Span, err error) {
+	span.SetAttributes(attrDecision.String(metrics.ResultError))
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
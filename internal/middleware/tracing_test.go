@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/configs"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/limiter"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/storage"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/tracing"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestRateLimiterMiddleware_Tracing(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := tracing.NewProvider("test", sdktrace.WithSyncer(exporter))
+
+	cfg := &configs.Config{
+		RateLimitIP:          1,
+		RateLimitIPBlockTime: 1,
+	}
+	store := tracing.InstrumentStorage(storage.NewMemoryStorage(), tp, "memory")
+	middleware := RateLimiterMiddleware(cfg, limiter.NewRateLimiter(store), WithTracerProvider(tp))
+
+	var handlerSpan trace.SpanContext
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlerSpan = trace.SpanContextFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func() {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "192.168.1.1:1234"
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	send()
+	send()
+
+	var middlewareSpans []tracetest.SpanStub
+	var storageSpans int
+	for _, span := range exporter.GetSpans() {
+		if span.Name == "RateLimiterMiddleware" {
+			middlewareSpans = append(middlewareSpans, span)
+		} else {
+			storageSpans++
+		}
+	}
+	assert.Len(t, middlewareSpans, 2)
+	assert.Positive(t, storageSpans)
+
+	// The trace of the incoming request is continued
+	allowed := middlewareSpans[0]
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", allowed.SpanContext.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", allowed.Parent.SpanID().String())
+	assert.Equal(t, allowed.SpanContext.SpanID(), handlerSpan.SpanID())
+	assert.Contains(t, allowed.Attributes, attribute.String("ratelimit.decision", "allowed"))
+	assert.Contains(t, allowed.Attributes, attribute.String("ratelimit.rule", "default"))
+	assert.Contains(t, allowed.Attributes, attribute.String("ratelimit.key_type", "ip"))
+
+	rejected := middlewareSpans[1]
+	assert.Contains(t, rejected.Attributes, attribute.String("ratelimit.decision", "rejected"))
+	assert.Contains(t, rejected.Attributes, attribute.String("ratelimit.reason", "exceeded"))
+}
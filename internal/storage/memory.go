@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"log/slog"
 	"sync"
 	"time"
 )
@@ -9,6 +10,7 @@ import (
 type MemoryStorage struct {
 	counters map[string]counterEntry
 	blocks   map[string]time.Time
+	tokens   map[string]TokenRecord
 	mu       sync.RWMutex
 }
 
@@ -21,6 +23,7 @@ func NewMemoryStorage() *MemoryStorage {
 	storage := &MemoryStorage{
 		counters: make(map[string]counterEntry),
 		blocks:   make(map[string]time.Time),
+		tokens:   make(map[string]TokenRecord),
 	}
 
 	// Start cleanup goroutine
@@ -67,6 +70,26 @@ func (m *MemoryStorage) Get(ctx context.Context, key string) (int64, error) {
 	return entry.count, nil
 }
 
+func (m *MemoryStorage) TTL(ctx context.Context, key string) (time.Duration, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entry, exists := m.counters[key]
+	if !exists {
+		return 0, nil
+	}
+
+	return max(time.Until(entry.expiration), 0), nil
+}
+
+func (m *MemoryStorage) Reset(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.counters, key)
+	return nil
+}
+
 func (m *MemoryStorage) SetBlock(ctx context.Context, key string, duration time.Duration) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -91,6 +114,97 @@ func (m *MemoryStorage) IsBlocked(ctx context.Context, key string) (bool, error)
 	return true, nil
 }
 
+func (m *MemoryStorage) BlockTTL(ctx context.Context, key string) (time.Duration, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	blockUntil, exists := m.blocks[key]
+	if !exists {
+		return 0, nil
+	}
+
+	return max(time.Until(blockUntil), 0), nil
+}
+
+func (m *MemoryStorage) Unblock(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.blocks, key)
+	return nil
+}
+
+func (m *MemoryStorage) ListBlocks(ctx context.Context) ([]Block, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	blocks := make([]Block, 0, len(m.blocks))
+	for key, blockUntil := range m.blocks {
+		if now.After(blockUntil) {
+			continue
+		}
+		blocks = append(blocks, Block{Key: key, ExpiresAt: blockUntil})
+	}
+
+	return blocks, nil
+}
+
+func (m *MemoryStorage) ListCounters(ctx context.Context) ([]Counter, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	counters := make([]Counter, 0, len(m.counters))
+	for key, entry := range m.counters {
+		if now.After(entry.expiration) {
+			continue
+		}
+		counters = append(counters, Counter{Key: key, Count: entry.count, ExpiresAt: entry.expiration})
+	}
+
+	return counters, nil
+}
+
+func (m *MemoryStorage) GetToken(ctx context.Context, hash string) (TokenRecord, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	record, exists := m.tokens[hash]
+	return record, exists, nil
+}
+
+func (m *MemoryStorage) SetToken(ctx context.Context, hash string, record TokenRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.tokens[hash] = record
+	return nil
+}
+
+func (m *MemoryStorage) DeleteToken(ctx context.Context, hash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.tokens, hash)
+	return nil
+}
+
+func (m *MemoryStorage) ListTokens(ctx context.Context) (map[string]TokenRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tokens := make(map[string]TokenRecord, len(m.tokens))
+	for hash, record := range m.tokens {
+		tokens[hash] = record
+	}
+	return tokens, nil
+}
+
+func (m *MemoryStorage) Ping(ctx context.Context) error {
+	return nil
+}
+
 func (m *MemoryStorage) Close() error {
 	return nil
 }
@@ -103,11 +217,13 @@ func (m *MemoryStorage) cleanup() {
 	for range ticker.C {
 		m.mu.Lock()
 		now := time.Now()
+		removed := 0
 
 		// Clean up expired counters
 		for key, entry := range m.counters {
 			if now.After(entry.expiration) {
 				delete(m.counters, key)
+				removed++
 			}
 		}
 
@@ -115,9 +231,12 @@ func (m *MemoryStorage) cleanup() {
 		for key, blockUntil := range m.blocks {
 			if now.After(blockUntil) {
 				delete(m.blocks, key)
+				removed++
 			}
 		}
 
 		m.mu.Unlock()
+
+		slog.Debug("Expired entries removed from memory storage", "removed", removed)
 	}
 }
@@ -115,3 +115,115 @@ func TestMemoryStorage_Concurrent(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, int64(10), count)
 }
+
+func TestMemoryStorage_Reset(t *testing.T) {
+	storage := NewMemoryStorage()
+	ctx := context.Background()
+
+	_, err := storage.Increment(ctx, "test-key", 1*time.Second)
+	assert.NoError(t, err)
+
+	err = storage.Reset(ctx, "test-key")
+	assert.NoError(t, err)
+
+	count, err := storage.Get(ctx, "test-key")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestMemoryStorage_UnblockAndListBlocks(t *testing.T) {
+	storage := NewMemoryStorage()
+	ctx := context.Background()
+
+	assert.NoError(t, storage.SetBlock(ctx, "key1", 1*time.Second))
+	assert.NoError(t, storage.SetBlock(ctx, "key2", 1*time.Second))
+	assert.NoError(t, storage.SetBlock(ctx, "expired", -1*time.Second))
+
+	blocks, err := storage.ListBlocks(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, blocks, 2)
+
+	// Unblock one key
+	err = storage.Unblock(ctx, "key1")
+	assert.NoError(t, err)
+
+	blocked, err := storage.IsBlocked(ctx, "key1")
+	assert.NoError(t, err)
+	assert.False(t, blocked)
+
+	blocks, err = storage.ListBlocks(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, blocks, 1)
+	assert.Equal(t, "key2", blocks[0].Key)
+}
+
+func TestMemoryStorage_ListCounters(t *testing.T) {
+	storage := NewMemoryStorage()
+	ctx := context.Background()
+
+	_, err := storage.Increment(ctx, "key1", 1*time.Second)
+	assert.NoError(t, err)
+	_, err = storage.Increment(ctx, "key1", 1*time.Second)
+	assert.NoError(t, err)
+	_, err = storage.Increment(ctx, "expired", -1*time.Second)
+	assert.NoError(t, err)
+
+	counters, err := storage.ListCounters(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, counters, 1)
+	assert.Equal(t, "key1", counters[0].Key)
+	assert.Equal(t, int64(2), counters[0].Count)
+}
+
+func TestMemoryStorage_TTL(t *testing.T) {
+	storage := NewMemoryStorage()
+	ctx := context.Background()
+
+	ttl, err := storage.TTL(ctx, "test-key")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), ttl)
+
+	_, err = storage.Increment(ctx, "test-key", 1*time.Second)
+	assert.NoError(t, err)
+
+	ttl, err = storage.TTL(ctx, "test-key")
+	assert.NoError(t, err)
+	assert.InDelta(t, float64(1*time.Second), float64(ttl), float64(50*time.Millisecond))
+
+	blockTTL, err := storage.BlockTTL(ctx, "test-key")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), blockTTL)
+
+	err = storage.SetBlock(ctx, "test-key", 2*time.Second)
+	assert.NoError(t, err)
+
+	blockTTL, err = storage.BlockTTL(ctx, "test-key")
+	assert.NoError(t, err)
+	assert.InDelta(t, float64(2*time.Second), float64(blockTTL), float64(50*time.Millisecond))
+}
+
+func TestMemoryStorage_Tokens(t *testing.T) {
+	storage := NewMemoryStorage()
+	defer storage.Close()
+	ctx := context.Background()
+
+	_, found, err := storage.GetToken(ctx, "hash")
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	record := TokenRecord{Name: "acme", Tier: "pro", Limit: 10}
+	assert.NoError(t, storage.SetToken(ctx, "hash", record))
+
+	got, found, err := storage.GetToken(ctx, "hash")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, record, got)
+
+	tokens, err := storage.ListTokens(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]TokenRecord{"hash": record}, tokens)
+
+	assert.NoError(t, storage.DeleteToken(ctx, "hash"))
+	_, found, _ = storage.GetToken(ctx, "hash")
+	assert.False(t, found)
+}
@@ -2,8 +2,11 @@ package storage
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -26,24 +29,32 @@ func NewRedisStorage(host, port, password string, db int) (*RedisStorage, error)
 	if err := client.Ping(ctx).Err(); err != nil {
 		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
 	}
+	slog.Debug("Connected to Redis", "addr", client.Options().Addr, "db", db)
 
 	return &RedisStorage{
 		client: client,
 	}, nil
 }
 
-func (r *RedisStorage) Increment(ctx context.Context, key string, expiration time.Duration) (int64, error) {
-	pipe := r.client.Pipeline()
-
-	incr := pipe.Incr(ctx, key)
-	pipe.Expire(ctx, key, expiration)
+// incrementScript increments a counter and sets its expiration only when
+// it has none, so the window is fixed rather than extended by every request,
+// like in the in-memory storage. It runs atomically and, unlike EXPIRE NX,
+// works on Redis versions older than 7.
+var incrementScript = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+if redis.call("PTTL", KEYS[1]) < 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return count
+`)
 
-	_, err := pipe.Exec(ctx)
+func (r *RedisStorage) Increment(ctx context.Context, key string, expiration time.Duration) (int64, error) {
+	count, err := incrementScript.Run(ctx, r.client, []string{key}, expiration.Milliseconds()).Int64()
 	if err != nil {
 		return 0, fmt.Errorf("failed to increment counter: %w", err)
 	}
 
-	return incr.Val(), nil
+	return count, nil
 }
 
 func (r *RedisStorage) Get(ctx context.Context, key string) (int64, error) {
@@ -58,6 +69,24 @@ func (r *RedisStorage) Get(ctx context.Context, key string) (int64, error) {
 	return val, nil
 }
 
+func (r *RedisStorage) TTL(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := r.client.PTTL(ctx, key).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get counter ttl: %w", err)
+	}
+
+	// Negative values mean the key does not exist or has no expiration
+	return max(ttl, 0), nil
+}
+
+func (r *RedisStorage) Reset(ctx context.Context, key string) error {
+	if err := r.client.Del(ctx, key).Err(); err != nil {
+		return fmt.Errorf("failed to reset counter: %w", err)
+	}
+
+	return nil
+}
+
 func (r *RedisStorage) SetBlock(ctx context.Context, key string, duration time.Duration) error {
 	blockKey := fmt.Sprintf("block:%s", key)
 	err := r.client.Set(ctx, blockKey, "1", duration).Err()
@@ -81,6 +110,161 @@ func (r *RedisStorage) IsBlocked(ctx context.Context, key string) (bool, error)
 	return val == "1", nil
 }
 
+func (r *RedisStorage) BlockTTL(ctx context.Context, key string) (time.Duration, error) {
+	blockKey := fmt.Sprintf("block:%s", key)
+	ttl, err := r.client.PTTL(ctx, blockKey).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to check block ttl: %w", err)
+	}
+
+	return max(ttl, 0), nil
+}
+
+func (r *RedisStorage) Unblock(ctx context.Context, key string) error {
+	blockKey := fmt.Sprintf("block:%s", key)
+	if err := r.client.Del(ctx, blockKey).Err(); err != nil {
+		return fmt.Errorf("failed to remove block: %w", err)
+	}
+
+	return nil
+}
+
+func (r *RedisStorage) ListBlocks(ctx context.Context) ([]Block, error) {
+	var blocks []Block
+
+	iter := r.client.Scan(ctx, 0, "block:*", 100).Iterator()
+	for iter.Next(ctx) {
+		blockKey := iter.Val()
+
+		ttl, err := r.client.PTTL(ctx, blockKey).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get block ttl: %w", err)
+		}
+		// Key expired between SCAN and PTTL, or has no expiration at all
+		if ttl <= 0 {
+			continue
+		}
+
+		blocks = append(blocks, Block{
+			Key:       strings.TrimPrefix(blockKey, "block:"),
+			ExpiresAt: time.Now().Add(ttl),
+		})
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list blocks: %w", err)
+	}
+
+	return blocks, nil
+}
+
+func (r *RedisStorage) ListCounters(ctx context.Context) ([]Counter, error) {
+	var counters []Counter
+
+	iter := r.client.Scan(ctx, 0, "*", 100).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		if strings.HasPrefix(key, "block:") {
+			continue
+		}
+
+		count, err := r.client.Get(ctx, key).Int64()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			// Not a counter managed by the rate limiter
+			slog.DebugContext(ctx, "Skipping Redis key that is not a counter", "key", key, "error", err)
+			continue
+		}
+
+		ttl, err := r.client.PTTL(ctx, key).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get counter ttl: %w", err)
+		}
+		if ttl <= 0 {
+			continue
+		}
+
+		counters = append(counters, Counter{
+			Key:       key,
+			Count:     count,
+			ExpiresAt: time.Now().Add(ttl),
+		})
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list counters: %w", err)
+	}
+
+	return counters, nil
+}
+
+// tokensKey is the Redis hash holding the token registry: one field per
+// token hash, with its record encoded as JSON
+const tokensKey = "tokens"
+
+func (r *RedisStorage) GetToken(ctx context.Context, hash string) (TokenRecord, bool, error) {
+	data, err := r.client.HGet(ctx, tokensKey, hash).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return TokenRecord{}, false, nil
+	}
+	if err != nil {
+		return TokenRecord{}, false, fmt.Errorf("failed to get token: %w", err)
+	}
+
+	var record TokenRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return TokenRecord{}, false, fmt.Errorf("failed to decode token: %w", err)
+	}
+
+	return record, true, nil
+}
+
+func (r *RedisStorage) SetToken(ctx context.Context, hash string, record TokenRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode token: %w", err)
+	}
+
+	if err := r.client.HSet(ctx, tokensKey, hash, data).Err(); err != nil {
+		return fmt.Errorf("failed to set token: %w", err)
+	}
+
+	return nil
+}
+
+func (r *RedisStorage) DeleteToken(ctx context.Context, hash string) error {
+	if err := r.client.HDel(ctx, tokensKey, hash).Err(); err != nil {
+		return fmt.Errorf("failed to delete token: %w", err)
+	}
+
+	return nil
+}
+
+func (r *RedisStorage) ListTokens(ctx context.Context) (map[string]TokenRecord, error) {
+	fields, err := r.client.HGetAll(ctx, tokensKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tokens: %w", err)
+	}
+
+	tokens := make(map[string]TokenRecord, len(fields))
+	for hash, data := range fields {
+		var record TokenRecord
+		if err := json.Unmarshal([]byte(data), &record); err != nil {
+			return nil, fmt.Errorf("failed to decode token: %w", err)
+		}
+		tokens[hash] = record
+	}
+
+	return tokens, nil
+}
+
+func (r *RedisStorage) Ping(ctx context.Context) error {
+	if err := r.client.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("failed to ping Redis: %w", err)
+	}
+	return nil
+}
+
 func (r *RedisStorage) Close() error {
 	return r.client.Close()
 }
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRedisStorage(t *testing.T) (*RedisStorage, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+
+	storage, err := NewRedisStorage(mr.Host(), mr.Port(), "", 0)
+	assert.NoError(t, err)
+	t.Cleanup(func() { storage.Close() })
+
+	return storage, mr
+}
+
+func TestRedisStorage_Increment(t *testing.T) {
+	storage, mr := newTestRedisStorage(t)
+	ctx := context.Background()
+
+	for i := int64(1); i <= 3; i++ {
+		count, err := storage.Increment(ctx, "test-key", 10*time.Second)
+		assert.NoError(t, err)
+		assert.Equal(t, i, count)
+	}
+	assert.Equal(t, 10*time.Second, mr.TTL("test-key"))
+}
+
+func TestRedisStorage_IncrementFixedWindow(t *testing.T) {
+	storage, mr := newTestRedisStorage(t)
+	ctx := context.Background()
+
+	_, err := storage.Increment(ctx, "test-key", 10*time.Second)
+	assert.NoError(t, err)
+
+	// Later increments do not extend the window
+	mr.FastForward(6 * time.Second)
+	_, err = storage.Increment(ctx, "test-key", 10*time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, 4*time.Second, mr.TTL("test-key"))
+
+	// Once the window is over the count starts again
+	mr.FastForward(4 * time.Second)
+	count, err := storage.Increment(ctx, "test-key", 10*time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	assert.Equal(t, 10*time.Second, mr.TTL("test-key"))
+}
+
+func TestRedisStorage_IncrementWithoutExpiration(t *testing.T) {
+	storage, mr := newTestRedisStorage(t)
+	ctx := context.Background()
+
+	// A counter left without an expiration gets one
+	mr.Set("test-key", "5")
+
+	count, err := storage.Increment(ctx, "test-key", 10*time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), count)
+	assert.Equal(t, 10*time.Second, mr.TTL("test-key"))
+}
@@ -12,12 +12,67 @@ type Storage interface {
 	// Get retrieves the current count for a given key
 	Get(ctx context.Context, key string) (int64, error)
 
+	// TTL returns how long until the counter for a given key expires
+	TTL(ctx context.Context, key string) (time.Duration, error)
+
+	// Reset removes the counter for a given key
+	Reset(ctx context.Context, key string) error
+
 	// SetBlock blocks a key for a specified duration
 	SetBlock(ctx context.Context, key string, duration time.Duration) error
 
 	// IsBlocked checks if a key is currently blocked
 	IsBlocked(ctx context.Context, key string) (bool, error)
 
+	// BlockTTL returns how long a key remains blocked, or 0 if it is not blocked
+	BlockTTL(ctx context.Context, key string) (time.Duration, error)
+
+	// Unblock removes the block for a given key, if any
+	Unblock(ctx context.Context, key string) error
+
+	// ListBlocks returns all keys that are currently blocked
+	ListBlocks(ctx context.Context) ([]Block, error)
+
+	// ListCounters returns all counters that have not expired yet
+	ListCounters(ctx context.Context) ([]Counter, error)
+
+	// GetToken looks up a registered token by the SHA-256 hash of its value
+	GetToken(ctx context.Context, hash string) (TokenRecord, bool, error)
+
+	// SetToken registers a token, or replaces its record, by hash
+	SetToken(ctx context.Context, hash string, record TokenRecord) error
+
+	// DeleteToken removes a registered token, if any
+	DeleteToken(ctx context.Context, hash string) error
+
+	// ListTokens returns every registered token, indexed by hash
+	ListTokens(ctx context.Context) (map[string]TokenRecord, error)
+
+	// Ping checks that the storage can be reached
+	Ping(ctx context.Context) error
+
 	// Close closes the storage connection
 	Close() error
 }
+
+// TokenRecord describes a token registered in storage. Only the hash of
+// the token is stored; zero limits are inherited from the tier.
+type TokenRecord struct {
+	Name      string `json:"name,omitempty"`
+	Tier      string `json:"tier,omitempty"`
+	Limit     int    `json:"limit,omitempty"`
+	BlockTime int    `json:"block_time,omitempty"`
+}
+
+// Block describes an active block on a key
+type Block struct {
+	Key       string
+	ExpiresAt time.Time
+}
+
+// Counter describes the current state of a key's counter
+type Counter struct {
+	Key       string
+	Count     int64
+	ExpiresAt time.Time
+}
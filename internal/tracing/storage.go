@@ -0,0 +1,151 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/storage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracedStorage creates a span for every operation of a storage
+type tracedStorage struct {
+	storage.Storage
+	tracer  trace.Tracer
+	backend string
+}
+
+// InstrumentStorage wraps s so that every operation is traced as a child of
+// the span in its context. backend names the storage ("redis", "memory").
+func InstrumentStorage(s storage.Storage, tp trace.TracerProvider, backend string) storage.Storage {
+	return &tracedStorage{Storage: s, tracer: tp.Tracer(TracerName), backend: backend}
+}
+
+// start begins the span of an operation; the returned function ends it
+// with the outcome and latency of the operation
+func (s *tracedStorage) start(ctx context.Context, operation string) (context.Context, func(error)) {
+	ctx, span := s.tracer.Start(ctx, "storage."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("ratelimit.storage.backend", s.backend),
+			attribute.String("ratelimit.storage.operation", operation),
+		),
+	)
+	start := time.Now()
+
+	return ctx, func(err error) {
+		span.SetAttributes(attribute.Float64("ratelimit.storage.latency_ms", float64(time.Since(start).Microseconds())/1000))
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+func (s *tracedStorage) Increment(ctx context.Context, key string, expiration time.Duration) (int64, error) {
+	ctx, end := s.start(ctx, "increment")
+	result, err := s.Storage.Increment(ctx, key, expiration)
+	end(err)
+	return result, err
+}
+
+func (s *tracedStorage) Get(ctx context.Context, key string) (int64, error) {
+	ctx, end := s.start(ctx, "get")
+	result, err := s.Storage.Get(ctx, key)
+	end(err)
+	return result, err
+}
+
+func (s *tracedStorage) TTL(ctx context.Context, key string) (time.Duration, error) {
+	ctx, end := s.start(ctx, "ttl")
+	result, err := s.Storage.TTL(ctx, key)
+	end(err)
+	return result, err
+}
+
+func (s *tracedStorage) Reset(ctx context.Context, key string) error {
+	ctx, end := s.start(ctx, "reset")
+	err := s.Storage.Reset(ctx, key)
+	end(err)
+	return err
+}
+
+func (s *tracedStorage) SetBlock(ctx context.Context, key string, duration time.Duration) error {
+	ctx, end := s.start(ctx, "set_block")
+	err := s.Storage.SetBlock(ctx, key, duration)
+	end(err)
+	return err
+}
+
+func (s *tracedStorage) IsBlocked(ctx context.Context, key string) (bool, error) {
+	ctx, end := s.start(ctx, "is_blocked")
+	result, err := s.Storage.IsBlocked(ctx, key)
+	end(err)
+	return result, err
+}
+
+func (s *tracedStorage) BlockTTL(ctx context.Context, key string) (time.Duration, error) {
+	ctx, end := s.start(ctx, "block_ttl")
+	result, err := s.Storage.BlockTTL(ctx, key)
+	end(err)
+	return result, err
+}
+
+func (s *tracedStorage) Unblock(ctx context.Context, key string) error {
+	ctx, end := s.start(ctx, "unblock")
+	err := s.Storage.Unblock(ctx, key)
+	end(err)
+	return err
+}
+
+func (s *tracedStorage) ListBlocks(ctx context.Context) ([]storage.Block, error) {
+	ctx, end := s.start(ctx, "list_blocks")
+	result, err := s.Storage.ListBlocks(ctx)
+	end(err)
+	return result, err
+}
+
+func (s *tracedStorage) ListCounters(ctx context.Context) ([]storage.Counter, error) {
+	ctx, end := s.start(ctx, "list_counters")
+	result, err := s.Storage.ListCounters(ctx)
+	end(err)
+	return result, err
+}
+
+func (s *tracedStorage) GetToken(ctx context.Context, hash string) (storage.TokenRecord, bool, error) {
+	ctx, end := s.start(ctx, "get_token")
+	record, ok, err := s.Storage.GetToken(ctx, hash)
+	end(err)
+	return record, ok, err
+}
+
+func (s *tracedStorage) SetToken(ctx context.Context, hash string, record storage.TokenRecord) error {
+	ctx, end := s.start(ctx, "set_token")
+	err := s.Storage.SetToken(ctx, hash, record)
+	end(err)
+	return err
+}
+
+func (s *tracedStorage) DeleteToken(ctx context.Context, hash string) error {
+	ctx, end := s.start(ctx, "delete_token")
+	err := s.Storage.DeleteToken(ctx, hash)
+	end(err)
+	return err
+}
+
+func (s *tracedStorage) ListTokens(ctx context.Context) (map[string]storage.TokenRecord, error) {
+	ctx, end := s.start(ctx, "list_tokens")
+	result, err := s.Storage.ListTokens(ctx)
+	end(err)
+	return result, err
+}
+
+func (s *tracedStorage) Ping(ctx context.Context) error {
+	ctx, end := s.start(ctx, "ping")
+	err := s.Storage.Ping(ctx)
+	end(err)
+	return err
+}
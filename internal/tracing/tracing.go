@@ -0,0 +1,89 @@
+// Package tracing sets up OpenTelemetry tracing for the rate limiter. Trace
+// context is propagated from incoming requests with the W3C traceparent and
+// baggage headers.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/configs"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// TracerName is the instrumentation scope of the spans of the rate limiter
+const TracerName = "github.com/jonilsonds9/goexpert-desafio-rate-limiter"
+
+// Setup creates the tracer provider for the configured exporter and makes
+// it, along with the W3C propagators, the global one. The returned function
+// flushes pending spans and must be called before exiting.
+func Setup(cfg *configs.Config, w io.Writer) (trace.TracerProvider, func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	switch cfg.TracingExporter {
+	case configs.TracingStdout:
+		var err error
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(w))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create stdout exporter: %w", err)
+		}
+	case configs.TracingOTLP:
+		// The exporter connects lazily: an unreachable collector only loses
+		// spans, it does not stop the server from starting
+		var err error
+		exporter, err = newOTLPExporter(cfg.TracingEndpoint)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+		}
+	default:
+		tp := noop.NewTracerProvider()
+		otel.SetTracerProvider(tp)
+		return tp, func(context.Context) error { return nil }, nil
+	}
+
+	tp := NewProvider(cfg.TracingServiceName, sdktrace.WithBatcher(exporter))
+	otel.SetTracerProvider(tp)
+	return tp, tp.Shutdown, nil
+}
+
+// newOTLPExporter returns an OTLP/HTTP exporter. As with the standard
+// OTEL_EXPORTER_OTLP_ENDPOINT variable, the endpoint is the base URL of the
+// collector and spans are sent to its /v1/traces path.
+func newOTLPExporter(endpoint string) (*otlptrace.Exporter, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(u.Host),
+		otlptracehttp.WithURLPath(path.Join("/", u.Path, "v1/traces")),
+	}
+	if u.Scheme != "https" {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	return otlptracehttp.New(context.Background(), opts...)
+}
+
+// NewProvider returns an SDK tracer provider for the service. Tests pass a
+// syncer to an in-memory exporter from sdk/trace/tracetest.
+func NewProvider(serviceName string, opts ...sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	res := resource.NewSchemaless(semconv.ServiceName(serviceName))
+	return sdktrace.NewTracerProvider(append([]sdktrace.TracerProviderOption{sdktrace.WithResource(res)}, opts...)...)
+}
@@ -0,0 +1,111 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/configs"
+	"github.com/jonilsonds9/goexpert-desafio-rate-limiter/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestInstrumentStorage(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := NewProvider("test", sdktrace.WithSyncer(exporter))
+
+	store := InstrumentStorage(storage.NewMemoryStorage(), tp, "memory")
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+	_, err := store.Increment(ctx, "ip:10.0.0.1", time.Second)
+	assert.NoError(t, err)
+	parent.End()
+
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 2)
+
+	span := spans[0]
+	assert.Equal(t, "storage.increment", span.Name)
+	assert.Equal(t, parent.SpanContext().SpanID(), span.Parent.SpanID())
+	assert.Contains(t, span.Attributes, attribute.String("ratelimit.storage.backend", "memory"))
+	assert.Contains(t, span.Attributes, attribute.String("ratelimit.storage.operation", "increment"))
+
+	var hasLatency bool
+	for _, attr := range span.Attributes {
+		hasLatency = hasLatency || attr.Key == "ratelimit.storage.latency_ms"
+	}
+	assert.True(t, hasLatency)
+}
+
+func TestSetup(t *testing.T) {
+	var buf bytes.Buffer
+	tp, shutdown, err := Setup(&configs.Config{TracingExporter: configs.TracingStdout, TracingServiceName: "rate-limiter"}, &buf)
+	assert.NoError(t, err)
+
+	_, span := tp.Tracer("test").Start(context.Background(), "exported")
+	span.End()
+	assert.NoError(t, shutdown(context.Background()))
+
+	assert.Contains(t, buf.String(), `"Name":"exported"`)
+	assert.Contains(t, buf.String(), "rate-limiter")
+
+	// Without an exporter spans are not recorded at all
+	tp, shutdown, err = Setup(&configs.Config{TracingExporter: configs.TracingNone}, &buf)
+	assert.NoError(t, err)
+	_, span = tp.Tracer("test").Start(context.Background(), "dropped")
+	assert.False(t, span.IsRecording())
+	assert.NoError(t, shutdown(context.Background()))
+}
+
+func TestSetup_OTLP(t *testing.T) {
+	// A stand-in for a local collector that records the spans it receives
+	var mu sync.Mutex
+	var paths, names []string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+
+		var data tracepb.TracesData
+		assert.NoError(t, proto.Unmarshal(body, &data))
+
+		mu.Lock()
+		defer mu.Unlock()
+		paths = append(paths, r.URL.Path)
+		for _, rs := range data.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				for _, span := range ss.Spans {
+					names = append(names, span.Name)
+				}
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer collector.Close()
+
+	cfg := &configs.Config{
+		TracingExporter:    configs.TracingOTLP,
+		TracingEndpoint:    collector.URL,
+		TracingServiceName: "rate-limiter",
+	}
+	tp, shutdown, err := Setup(cfg, io.Discard)
+	assert.NoError(t, err)
+
+	_, span := tp.Tracer("test").Start(context.Background(), "exported")
+	span.End()
+	assert.NoError(t, shutdown(context.Background()))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"/v1/traces"}, paths)
+	assert.Equal(t, []string{"exported"}, names)
+}